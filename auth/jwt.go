@@ -2,8 +2,14 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"math"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,11 +20,26 @@ import (
 var (
 	accessSecret    = []byte(os.Getenv("ACCESS_SECRET"))  // load from env in real deployment
 	refreshSecret   = []byte(os.Getenv("REFRESH_SECRET")) // separate key for refresh token
+	actionSecret    = []byte(os.Getenv("ACTION_SECRET"))  // separate key for action tokens
 	ErrTokenExpired = errors.New("token is expired")
+	ErrMissingToken = errors.New("authorization token is missing")
+	ErrInvalidAuth  = errors.New("authorization header must be Bearer <token>")
+	ErrWrongPurpose = errors.New("token was issued for another purpose")
+	ErrWrongType    = errors.New("token type does not match")
+	ErrNoSecret     = errors.New("signing secret is not set")
+	ErrTokenReused  = errors.New("refresh token was already used")
+)
+
+// token types carried in the "typ" claim, so a token signed for one use is rejected by the others
+const (
+	typeAccess  = "access"
+	typeRefresh = "refresh"
+	typeAction  = "action"
 )
 
 type Claims struct {
-	UserInfo map[string]string `json:"userinfo"`
+	UserInfo  map[string]string `json:"userinfo"`
+	TokenType string            `json:"typ,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -32,8 +53,10 @@ const ClaimsContextKey contextKey = "jwt_claims"
 func GenerateTokenPair(userInfo map[string]string) (string, string, error) {
 	// Access token expires fast
 	accessClaims := &Claims{
-		UserInfo: userInfo,
+		UserInfo:  userInfo,
+		TokenType: typeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "user-service",
@@ -46,8 +69,10 @@ func GenerateTokenPair(userInfo map[string]string) (string, string, error) {
 
 	// Refresh token lasts longer
 	refreshClaims := &Claims{
-		UserInfo: userInfo,
+		UserInfo:  userInfo,
+		TokenType: typeRefresh,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7 days
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "user-service",
@@ -61,26 +86,118 @@ func GenerateTokenPair(userInfo map[string]string) (string, string, error) {
 	return accessToken, refreshToken, nil
 }
 
+// ---------------------------
+// 🔸 Rotate refresh token
+// ---------------------------
+// RefreshTokenPair validates the refresh token and issues a brand-new pair with the same UserInfo
+// without a TokenRevoker (see SetTokenRevoker) the old refresh token stays valid until it expires;
+// with one, its jti is revoked and a second rotation with it returns ErrTokenReused
+func RefreshTokenPair(refreshToken string) (string, string, error) {
+	claims, err := ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	if revoker != nil {
+		if claims.ID == "" {
+			return "", "", ErrTokenReused
+		}
+		var expiresAt time.Time
+		if claims.ExpiresAt != nil {
+			expiresAt = claims.ExpiresAt.Time
+		}
+		fresh, err := revoker.Revoke(claims.ID, expiresAt)
+		if err != nil {
+			return "", "", err
+		}
+		if !fresh {
+			return "", "", ErrTokenReused
+		}
+	}
+
+	return GenerateTokenPair(claims.UserInfo)
+}
+
+// TokenRevoker records the jti of rotated refresh tokens, back it with a shared store (e.g Redis SETNX)
+// when several instances issue tokens
+type TokenRevoker interface {
+	// Revoke marks jti as used until expiresAt, false when it was already revoked;
+	// it must check and set atomically so two concurrent rotations cannot both succeed
+	Revoke(jti string, expiresAt time.Time) (bool, error)
+}
+
+var revoker TokenRevoker
+
+// SetTokenRevoker installs the revoker used by RefreshTokenPair, nil disables revocation
+// call it once at startup, before tokens are refreshed
+func SetTokenRevoker(r TokenRevoker) {
+	revoker = r
+}
+
+// MemoryRevoker is an in-process TokenRevoker, only suitable for a single instance
+type MemoryRevoker struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{revoked: map[string]time.Time{}}
+}
+
+func (r *MemoryRevoker) Revoke(jti string, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Expired tokens fail validation anyway, no need to remember them
+	now := time.Now()
+	for id, exp := range r.revoked {
+		if !exp.IsZero() && now.After(exp) {
+			delete(r.revoked, id)
+		}
+	}
+
+	if _, ok := r.revoked[jti]; ok {
+		return false, nil
+	}
+	r.revoked[jti] = expiresAt
+	return true, nil
+}
+
+// newTokenID returns a random jti so each issued token can be told apart
+func newTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
 // ---------------------------
 // 🔸 Validate token (access or refresh)
 // ---------------------------
+// ValidateAccessToken and ValidateRefreshToken also check the "typ" claim, expired tokens included,
+// so a refresh or action token never passes as an access token even when secrets are shared;
+// tokens without "typ" (issued before the claim was added) are still accepted
 func ValidateAccessToken(tokenStr string) (*Claims, error) {
-	return validateToken(tokenStr)
+	return validateToken(tokenStr, accessSecret, typeAccess)
 }
 
 func ValidateRefreshToken(tokenStr string) (*Claims, error) {
-	return validateToken(tokenStr)
+	return validateToken(tokenStr, refreshSecret, typeRefresh)
 }
 
-func validateToken(tokenStr string) (*Claims, error) {
+func validateToken(tokenStr string, secret []byte, tokenType string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-		return accessSecret, nil
-	})
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		// Handle expiration separately
 		if errors.Is(err, jwt.ErrTokenExpired) {
+			if !claims.hasType(tokenType) {
+				return nil, ErrWrongType
+			}
 			return claims, jwt.ErrTokenExpired
 		}
 		return nil, err
@@ -91,9 +208,169 @@ func validateToken(tokenStr string) (*Claims, error) {
 	}
 
 	tokenClaim := token.Claims.(*Claims)
+	if !tokenClaim.hasType(tokenType) {
+		return nil, ErrWrongType
+	}
 	return tokenClaim, nil
 }
 
+// hasType reports whether the "typ" claim matches, tokens issued before the claim existed carry none and pass
+func (c *Claims) hasType(tokenType string) bool {
+	return c.TokenType == "" || c.TokenType == tokenType
+}
+
+// ---------------------------
+// 🔸 Action tokens (password reset, email verification)
+// ---------------------------
+// ActionClaims are the claims of a single-purpose action token, Subject identifies the user
+type ActionClaims struct {
+	Purpose   string `json:"purpose"`
+	TokenType string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// GenerateActionToken issues a short-lived token for one purpose, e.g ("password-reset", userID, 30*time.Minute)
+// signed with ACTION_SECRET and typed "action", so access and refresh validation reject it;
+// returns ErrNoSecret when ACTION_SECRET is empty.
+// the token is stateless, store its jti once used to make it truly one-time
+func GenerateActionToken(purpose string, subject string, ttl time.Duration) (string, error) {
+	if purpose == "" {
+		return "", errors.New("action token purpose is required")
+	}
+	if len(actionSecret) == 0 {
+		return "", ErrNoSecret
+	}
+
+	claims := &ActionClaims{
+		Purpose:   purpose,
+		TokenType: typeAction,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "user-service",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(actionSecret)
+}
+
+// ValidateActionToken verifies an action token and returns its subject
+// returns ErrWrongPurpose when the token was issued for another purpose
+func ValidateActionToken(token, expectedPurpose string) (string, error) {
+	if len(actionSecret) == 0 {
+		return "", ErrNoSecret
+	}
+
+	claims := &ActionClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return actionSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return "", jwt.ErrTokenExpired
+		}
+		return "", err
+	}
+
+	if claims.TokenType != typeAction {
+		return "", ErrWrongType
+	}
+	if claims.Purpose != expectedPurpose {
+		return "", ErrWrongPurpose
+	}
+	return claims.Subject, nil
+}
+
+// ---------------------------
+// 🔸 Token remaining TTL
+// ---------------------------
+// TokenTimeUntilExpiry returns the time left before claims expire, negative once expired
+// ValidateAccessToken still returns the claims of an expired token, so middleware can use this
+// to decide on a silent refresh; claims without expiry return the maximum duration
+func TokenTimeUntilExpiry(claims *Claims) time.Duration {
+	if claims == nil {
+		return 0
+	}
+	if claims.ExpiresAt == nil {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Until(claims.ExpiresAt.Time)
+}
+
+// ---------------------------
+// 🔸 Parse WITHOUT verification (debug only)
+// ---------------------------
+// ParseUnverified decodes the claims of a token WITHOUT checking its signature or expiry.
+// Anyone can forge such a token: NEVER use the result for authorization decisions,
+// only for debugging or log correlation (e.g in a gateway that does not hold the secret).
+func ParseUnverified(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenStr, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ---------------------------
+// 🔸 Role / scope checks
+// ---------------------------
+// HasRole reports whether the "roles" entry of UserInfo (space or comma separated) contains role
+func (c *Claims) HasRole(role string) bool {
+	return c.hasValue("roles", role)
+}
+
+// HasScope reports whether the "scopes" entry of UserInfo (space or comma separated) contains scope
+func (c *Claims) HasScope(scope string) bool {
+	return c.hasValue("scopes", scope)
+}
+
+func (c *Claims) hasValue(key, want string) bool {
+	if c == nil || want == "" {
+		return false
+	}
+
+	values := strings.FieldsFunc(c.UserInfo[key], func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole rejects requests whose claims do not carry role
+// claims already in the context are reused, otherwise the Bearer token is validated
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetClaimsFromContext(r.Context())
+			if !ok {
+				token, err := ExtractBearerToken(r)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				claims, err = ValidateAccessToken(token)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				r = r.WithContext(context.WithValue(r.Context(), ClaimsContextKey, claims))
+			}
+
+			if !claims.HasRole(role) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // ---------------------------
 // 🔸 Get claims from context
 // ---------------------------
@@ -127,3 +404,69 @@ func IncomingContext(ctx context.Context, out interface{}) error {
 
 	return common.MapToStruct(auth, out)
 }
+
+// ---------------------------
+// 🔸 Forward claims over HTTP headers
+// ---------------------------
+const userHeaderPrefix = "X-User-"
+
+// InjectToHTTPHeader writes the UserInfo of claims as X-User-<key> headers, the HTTP counterpart of InjectToGRPCContext
+// the headers are not signed: only trust them between internal services and strip them at the edge
+func InjectToHTTPHeader(h http.Header, claims *Claims) {
+	if claims == nil {
+		return
+	}
+	for k, v := range claims.UserInfo {
+		h.Set(userHeaderPrefix+k, v)
+	}
+}
+
+// ClaimsFromHTTPHeader reads back the X-User-* headers written by InjectToHTTPHeader
+// keys come back lowercased like gRPC metadata keys, false when no X-User-* header is present
+func ClaimsFromHTTPHeader(h http.Header) (*Claims, bool) {
+	userInfo := map[string]string{}
+	for k, vals := range h {
+		if len(vals) == 0 || len(k) <= len(userHeaderPrefix) || !strings.EqualFold(k[:len(userHeaderPrefix)], userHeaderPrefix) {
+			continue
+		}
+		userInfo[strings.ToLower(k[len(userHeaderPrefix):])] = vals[0]
+	}
+
+	if len(userInfo) == 0 {
+		return nil, false
+	}
+	return &Claims{UserInfo: userInfo}, true
+}
+
+// ---------------------------
+// 🔸 Extract bearer token (HTTP + gRPC)
+// ---------------------------
+func ExtractBearerToken(r *http.Request) (string, error) {
+	return parseBearer(r.Header.Get("Authorization"))
+}
+
+func ExtractTokenFromMetadata(md metadata.MD) (string, error) {
+	val := md.Get("authorization")
+	if len(val) == 0 {
+		return "", ErrMissingToken
+	}
+	return parseBearer(val[0])
+}
+
+func parseBearer(header string) (string, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return "", ErrMissingToken
+	}
+
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrInvalidAuth
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrMissingToken
+	}
+	return token, nil
+}
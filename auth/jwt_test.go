@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func withSecrets(t *testing.T, access, refresh, action string) {
+	t.Helper()
+	oldAccess, oldRefresh, oldAction := accessSecret, refreshSecret, actionSecret
+	accessSecret, refreshSecret, actionSecret = []byte(access), []byte(refresh), []byte(action)
+	t.Cleanup(func() {
+		accessSecret, refreshSecret, actionSecret = oldAccess, oldRefresh, oldAction
+	})
+}
+
+func TestActionTokenRequiresSecret(t *testing.T) {
+	withSecrets(t, "a", "r", "")
+
+	if _, err := GenerateActionToken("password-reset", "u1", time.Minute); !errors.Is(err, ErrNoSecret) {
+		t.Fatalf("GenerateActionToken() err = %v, want ErrNoSecret", err)
+	}
+	if _, err := ValidateActionToken("x.y.z", "password-reset"); !errors.Is(err, ErrNoSecret) {
+		t.Fatalf("ValidateActionToken() err = %v, want ErrNoSecret", err)
+	}
+}
+
+func TestTokenTypesAreNotInterchangeable(t *testing.T) {
+	// one shared secret: only the typ claim tells the tokens apart
+	withSecrets(t, "shared", "shared", "shared")
+
+	access, refresh, err := GenerateTokenPair(map[string]string{"id": "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	action, err := GenerateActionToken("password-reset", "u1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ValidateAccessToken(access); err != nil {
+		t.Fatalf("ValidateAccessToken(access) err = %v", err)
+	}
+	if _, err := ValidateRefreshToken(refresh); err != nil {
+		t.Fatalf("ValidateRefreshToken(refresh) err = %v", err)
+	}
+	if sub, err := ValidateActionToken(action, "password-reset"); err != nil || sub != "u1" {
+		t.Fatalf("ValidateActionToken(action) = %q, %v", sub, err)
+	}
+
+	for name, check := range map[string]func() error{
+		"refresh as access": func() error { _, err := ValidateAccessToken(refresh); return err },
+		"action as access":  func() error { _, err := ValidateAccessToken(action); return err },
+		"access as refresh": func() error { _, err := ValidateRefreshToken(access); return err },
+		"action as refresh": func() error { _, err := ValidateRefreshToken(action); return err },
+		"access as action":  func() error { _, err := ValidateActionToken(access, ""); return err },
+	} {
+		if err := check(); !errors.Is(err, ErrWrongType) {
+			t.Errorf("%s: err = %v, want ErrWrongType", name, err)
+		}
+	}
+}
+
+func TestRefreshTokenPairRevokesOldToken(t *testing.T) {
+	withSecrets(t, "a", "r", "")
+	SetTokenRevoker(NewMemoryRevoker())
+	t.Cleanup(func() { SetTokenRevoker(nil) })
+
+	_, refresh, err := GenerateTokenPair(map[string]string{"id": "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rotated, err := RefreshTokenPair(refresh)
+	if err != nil {
+		t.Fatalf("first RefreshTokenPair() err = %v", err)
+	}
+	if _, _, err := RefreshTokenPair(refresh); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("reused RefreshTokenPair() err = %v, want ErrTokenReused", err)
+	}
+	if _, _, err := RefreshTokenPair(rotated); err != nil {
+		t.Fatalf("rotated RefreshTokenPair() err = %v", err)
+	}
+}
+
+func TestRefreshTokenPairWithoutRevoker(t *testing.T) {
+	withSecrets(t, "a", "r", "")
+
+	_, refresh, err := GenerateTokenPair(map[string]string{"id": "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := RefreshTokenPair(refresh); err != nil {
+			t.Fatalf("RefreshTokenPair() #%d err = %v", i, err)
+		}
+	}
+}
+
+// sign issues a token with raw claims, e.g without "typ" like tokens issued before the claim existed
+func sign(t *testing.T, claims jwt.Claims, secret []byte) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestUntypedTokensStillValid(t *testing.T) {
+	withSecrets(t, "a", "r", "")
+
+	legacy := &Claims{
+		UserInfo: map[string]string{"id": "u1"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	if _, err := ValidateAccessToken(sign(t, legacy, accessSecret)); err != nil {
+		t.Fatalf("ValidateAccessToken(untyped) err = %v", err)
+	}
+	if _, err := ValidateRefreshToken(sign(t, legacy, refreshSecret)); err != nil {
+		t.Fatalf("ValidateRefreshToken(untyped) err = %v", err)
+	}
+}
+
+func TestExpiredTokenTypeChecked(t *testing.T) {
+	withSecrets(t, "shared", "shared", "shared")
+
+	expired := jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))}
+	refresh := sign(t, &Claims{TokenType: typeRefresh, RegisteredClaims: expired}, accessSecret)
+	if claims, err := ValidateAccessToken(refresh); !errors.Is(err, ErrWrongType) || claims != nil {
+		t.Fatalf("expired refresh as access = %v, %v, want nil, ErrWrongType", claims, err)
+	}
+
+	access := sign(t, &Claims{TokenType: typeAccess, RegisteredClaims: expired}, accessSecret)
+	if claims, err := ValidateAccessToken(access); !errors.Is(err, jwt.ErrTokenExpired) || claims == nil {
+		t.Fatalf("expired access = %v, %v, want claims, ErrTokenExpired", claims, err)
+	}
+}
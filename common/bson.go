@@ -0,0 +1,273 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MergeBSON deep-merges src into a copy of dst, nested documents (bson.M, or bson.D as decoded by the driver)
+// are merged recursively into bson.M and src wins on any other conflict; dst and src are left untouched
+func MergeBSON(dst, src bson.M) bson.M {
+	out := make(bson.M, len(dst)+len(src))
+	for k, v := range dst {
+		out[k] = v
+	}
+
+	for k, v := range src {
+		srcDoc, srcIsDoc := asBSONMap(v)
+		dstDoc, dstIsDoc := asBSONMap(out[k])
+		if srcIsDoc && dstIsDoc {
+			out[k] = MergeBSON(dstDoc, srcDoc)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// asBSONMap returns v as bson.M when it is a document, including the bson.D and bson.Raw
+// the driver decodes nested documents into
+func asBSONMap(v interface{}) (bson.M, bool) {
+	switch val := v.(type) {
+	case bson.M:
+		return val, true
+	case map[string]interface{}:
+		return bson.M(val), true
+	case bson.D:
+		out := make(bson.M, len(val))
+		for _, elem := range val {
+			out[elem.Key] = elem.Value
+		}
+		return out, true
+	case bson.Raw:
+		var out bson.M
+		if err := bson.Unmarshal(val, &out); err != nil {
+			return nil, false
+		}
+		return out, true
+	default:
+		return nil, false
+	}
+}
+
+// CloneBSON deep-copies m (nested maps, documents and slices) so the copy can be mutated safely
+// callers building reusable query fragments should clone them before adding to them
+func CloneBSON(m bson.M) bson.M {
+	if m == nil {
+		return nil
+	}
+	return cloneBSONValue(m).(bson.M)
+}
+
+func cloneBSONValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case bson.M:
+		out := make(bson.M, len(val))
+		for k, item := range val {
+			out[k] = cloneBSONValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = cloneBSONValue(item)
+		}
+		return out
+	case bson.D:
+		out := make(bson.D, len(val))
+		for i, elem := range val {
+			out[i] = bson.E{Key: elem.Key, Value: cloneBSONValue(elem.Value)}
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, len(val))
+		for i, item := range val {
+			out[i] = cloneBSONValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = cloneBSONValue(item)
+		}
+		return out
+	case []bson.M:
+		out := make([]bson.M, len(val))
+		for i, item := range val {
+			out[i] = cloneBSONValue(item).(bson.M)
+		}
+		return out
+	case []bson.D:
+		out := make([]bson.D, len(val))
+		for i, item := range val {
+			out[i] = cloneBSONValue(item).(bson.D)
+		}
+		return out
+	case []string:
+		return append([]string(nil), val...)
+	case []byte:
+		return append([]byte(nil), val...)
+	default:
+		// Scalars and value types (ObjectID, DateTime, time.Time...) are copied by assignment
+		return v
+	}
+}
+
+// GetPath walks m along a dotted path, e.g GetPath(doc, "address.city") or "items.0.sku" for array elements
+// returns (nil, false) when any segment is missing or not a document/array
+func GetPath(m bson.M, path string) (interface{}, bool) {
+	var cur interface{} = m
+	for _, key := range strings.Split(path, ".") {
+		switch val := cur.(type) {
+		case bson.M:
+			next, ok := val[key]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case map[string]interface{}:
+			next, ok := val[key]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case bson.D:
+			found := false
+			for _, elem := range val {
+				if elem.Key == key {
+					cur, found = elem.Value, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		case bson.A:
+			next, ok := indexPath([]interface{}(val), key)
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			next, ok := indexPath(val, key)
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// indexPath returns the array element at the numeric path segment key
+func indexPath(arr []interface{}, key string) (interface{}, bool) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || i >= len(arr) {
+		return nil, false
+	}
+	return arr[i], true
+}
+
+// GetString returns the value at path converted with ParseString
+func GetString(m bson.M, path string) (string, bool) {
+	v, ok := GetPath(m, path)
+	if !ok {
+		return "", false
+	}
+	return ParseString(v), true
+}
+
+// GetInt returns the value at path converted with ParseInt
+func GetInt(m bson.M, path string) (int, bool) {
+	v, ok := GetPath(m, path)
+	if !ok {
+		return 0, false
+	}
+	return ParseInt(v), true
+}
+
+// GetTime returns the value at path converted with ParseTime, bson dates included
+// false when the path is missing or the value is not a time
+func GetTime(m bson.M, path string) (time.Time, bool) {
+	v, ok := GetPath(m, path)
+	if !ok {
+		return time.Time{}, false
+	}
+	if dt, isDate := v.(bson.DateTime); isDate {
+		return FromBSONTime(dt), true
+	}
+	t := ParseTime(v)
+	return t, !t.IsZero()
+}
+
+// HashDocument returns a SHA-256 hex digest of v (struct, bson.M, bson.D...) that ignores key order
+// so semantically identical documents hash the same, e.g to skip an upsert when nothing changed
+// numbers are compared by value, an int32 1 and an int64 1 hash the same
+func HashDocument(v interface{}) (string, error) {
+	// Relaxed extended JSON keeps ObjectIDs and dates distinguishable from plain strings
+	extJSON, err := bson.MarshalExtJSON(v, false, false)
+	if err != nil {
+		return "", err
+	}
+
+	// Round trip through maps, json.Marshal writes map keys sorted
+	decoder := json.NewDecoder(bytes.NewReader(extJSON))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DiffBSON compares two documents field by field, recursing into nested documents, e.g for audit trails
+// keys are dotted paths ("address.city"); added and removed hold the new and old values,
+// changed holds bson.M{"old": ..., "new": ...}; arrays and other values are compared as a whole
+func DiffBSON(old, new bson.M) (added, removed, changed bson.M) {
+	added, removed, changed = bson.M{}, bson.M{}, bson.M{}
+	diffBSON("", old, new, added, removed, changed)
+	return added, removed, changed
+}
+
+func diffBSON(prefix string, old, new bson.M, added, removed, changed bson.M) {
+	for k, oldVal := range old {
+		path := prefix + k
+		newVal, ok := new[k]
+		if !ok {
+			removed[path] = oldVal
+			continue
+		}
+
+		oldDoc, oldIsDoc := asBSONMap(oldVal)
+		newDoc, newIsDoc := asBSONMap(newVal)
+		if oldIsDoc && newIsDoc {
+			diffBSON(path+".", oldDoc, newDoc, added, removed, changed)
+			continue
+		}
+		if !reflect.DeepEqual(oldVal, newVal) {
+			changed[path] = bson.M{"old": oldVal, "new": newVal}
+		}
+	}
+
+	for k, newVal := range new {
+		if _, ok := old[k]; !ok {
+			added[prefix+k] = newVal
+		}
+	}
+}
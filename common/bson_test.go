@@ -0,0 +1,101 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// decoded round-trips doc through bson like a Find result, nested documents come back as bson.D
+func decoded(t *testing.T, doc bson.M) bson.M {
+	t.Helper()
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bson.M
+	if err := bson.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDiffBSONDecodedDocuments(t *testing.T) {
+	old := decoded(t, bson.M{
+		"name": "a",
+		"address": bson.M{
+			"city": "Jakarta",
+			"geo":  bson.M{"lat": 1.5, "lng": 2.5},
+			"zip":  "10110",
+		},
+	})
+	new := decoded(t, bson.M{
+		"name": "a",
+		"address": bson.M{
+			"city":    "Bandung",
+			"geo":     bson.M{"lat": 1.5, "lng": 3.5},
+			"country": "ID",
+		},
+	})
+
+	added, removed, changed := DiffBSON(old, new)
+
+	if want := (bson.M{"address.country": "ID"}); !reflect.DeepEqual(added, want) {
+		t.Errorf("added = %v, want %v", added, want)
+	}
+	if want := (bson.M{"address.zip": "10110"}); !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	want := bson.M{
+		"address.city":    bson.M{"old": "Jakarta", "new": "Bandung"},
+		"address.geo.lng": bson.M{"old": 2.5, "new": 3.5},
+	}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func TestDiffBSONRawSubdocument(t *testing.T) {
+	raw := func(doc bson.M) bson.Raw {
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	_, _, changed := DiffBSON(
+		bson.M{"meta": raw(bson.M{"v": int32(1), "tag": "x"})},
+		bson.M{"meta": raw(bson.M{"v": int32(2), "tag": "x"})},
+	)
+	if want := (bson.M{"meta.v": bson.M{"old": int32(1), "new": int32(2)}}); !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func TestMergeBSONDecodedDocuments(t *testing.T) {
+	dst := decoded(t, bson.M{
+		"name":    "a",
+		"address": bson.M{"city": "Jakarta", "zip": "10110", "geo": bson.M{"lat": 1.5}},
+	})
+	src := decoded(t, bson.M{
+		"address": bson.M{"city": "Bandung", "geo": bson.M{"lng": 2.5}},
+	})
+
+	got := MergeBSON(dst, src)
+	want := bson.M{
+		"name": "a",
+		"address": bson.M{
+			"city": "Bandung",
+			"zip":  "10110",
+			"geo":  bson.M{"lat": 1.5, "lng": 2.5},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MergeBSON() = %v, want %v", got, want)
+	}
+	if _, ok := dst["address"].(bson.D); !ok {
+		t.Fatalf("dst was modified: address is %T", dst["address"])
+	}
+}
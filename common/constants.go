@@ -53,4 +53,8 @@ const (
 	TimeFormatUnixMilli = "unix-milli" // Unix timestamp in milliseconds
 	TimeFormatUnixMicro = "unix-micro" // Unix timestamp in microseconds
 	TimeFormatUnixNano  = "unix-nano"  // Unix timestamp in nanoseconds
+
+	// ISO 8601 dates Go layouts can't express
+	TimeFormatISOWeek    = "iso-week"    // YYYY-Www-D or YYYY-Www (Monday), e.g 2024-W42-1
+	TimeFormatISOOrdinal = "iso-ordinal" // YYYY-DDD day of the year, e.g 2024-287
 )
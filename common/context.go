@@ -0,0 +1,21 @@
+package common
+
+import "context"
+
+// contextKey is private so values set here never collide with other packages' context keys
+type contextKey struct {
+	name string
+}
+
+// ContextSet returns a copy of ctx carrying val under key
+// e.g ctx = common.ContextSet(ctx, "request_id", id)
+func ContextSet[T any](ctx context.Context, key string, val T) context.Context {
+	return context.WithValue(ctx, contextKey{name: key}, val)
+}
+
+// ContextGet returns the value stored under key by ContextSet, false when missing or of another type
+// e.g id, ok := common.ContextGet[string](ctx, "request_id")
+func ContextGet[T any](ctx context.Context, key string) (T, bool) {
+	val, ok := ctx.Value(contextKey{name: key}).(T)
+	return val, ok
+}
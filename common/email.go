@@ -0,0 +1,36 @@
+package common
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// NormalizeEmail trims s, lowercases its domain and validates its basic shape
+// pass lowerLocal true to also lowercase the local part (most providers treat it case-insensitively)
+// e.g NormalizeEmail(" John@Example.COM ") = "John@example.com"
+func NormalizeEmail(s string, lowerLocal ...bool) (string, error) {
+	s = strings.TrimSpace(s)
+
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok || local == "" || domain == "" || strings.Contains(domain, "@") {
+		return "", ErrInvalidEmail
+	}
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", ErrInvalidEmail
+	}
+
+	if len(lowerLocal) > 0 && lowerLocal[0] {
+		local = strings.ToLower(local)
+	}
+	email := local + "@" + strings.ToLower(domain)
+
+	// Reject display names, spaces and other characters outside the RFC 5322 address shape
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
@@ -1,7 +1,9 @@
 package common
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"runtime"
 )
@@ -44,3 +46,40 @@ func ErrorUpdate(err error) error {
 func ErrorDelete(err error) error {
 	return Error("failed to delete", err)
 }
+
+// ErrTimeout matches (errors.Is) every error of an operation that ran past its deadline
+var ErrTimeout = errors.New("operation timed out")
+
+// TimeoutError wraps the error of a timed out operation, it unwraps to both ErrTimeout
+// and the original error, so errors.Is(err, context.DeadlineExceeded) keeps working
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrTimeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() []error {
+	return []error{ErrTimeout, e.Err}
+}
+
+// StatusCode is the HTTP status of a timed out operation, 504 Gateway Timeout
+func (e *TimeoutError) StatusCode() int {
+	return http.StatusGatewayTimeout
+}
+
+// HTTPStatus maps err to a response status: 504 for timeouts, 400 for validation errors, 500 otherwise
+func HTTPStatus(err error) int {
+	var verr *ValidationError
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &verr):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// KeysToSnake returns a copy of m with every key (nested maps and slices included) in snake_case
+// e.g {"firstName": "x", "homeAddress": {"zipCode": 1}} becomes {"first_name": "x", "home_address": {"zip_code": 1}}
+func KeysToSnake(m map[string]interface{}) map[string]interface{} {
+	return transformKeys(m, ToSnake)
+}
+
+// KeysToCamel returns a copy of m with every key (nested maps and slices included) in camelCase
+func KeysToCamel(m map[string]interface{}) map[string]interface{} {
+	return transformKeys(m, ToCamel)
+}
+
+// ToSnake converts a camelCase or PascalCase string to snake_case, e.g "userID" becomes "user_id"
+func ToSnake(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word on lower->Upper or on the last capital of an acronym (IDName -> id_name)
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ToCamel converts a snake_case string to camelCase, e.g "user_id" becomes "userId"
+// leading and trailing underscores are kept so "_id" and "__v" stay as they are,
+// a run of underscores between words is a single separator: "a__b" becomes "aB"
+func ToCamel(s string) string {
+	trimmed := strings.Trim(s, "_")
+	if trimmed == "" {
+		return s
+	}
+	lead := len(s) - len(strings.TrimLeft(s, "_"))
+	trail := len(s) - len(strings.TrimRight(s, "_"))
+
+	var b strings.Builder
+	b.WriteString(s[:lead])
+	for i, part := range strings.FieldsFunc(trimmed, func(r rune) bool { return r == '_' }) {
+		if i == 0 {
+			b.WriteString(part)
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	b.WriteString(s[len(s)-trail:])
+	return b.String()
+}
+
+func transformKeys(m map[string]interface{}, fn func(string) string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[fn(k)] = transformKeysValue(v, fn)
+	}
+	return out
+}
+
+func transformKeysValue(v interface{}, fn func(string) string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return transformKeys(val, fn)
+	case bson.M:
+		return transformKeys(val, fn)
+	case bson.A:
+		return transformKeysValue([]interface{}(val), fn)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = transformKeysValue(item, fn)
+		}
+		return out
+	case []map[string]interface{}:
+		out := make([]map[string]interface{}, len(val))
+		for i, item := range val {
+			out[i] = transformKeys(item, fn)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToCamel(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"user_id", "userId"},
+		{"first_name_x", "firstNameX"},
+		{"already", "already"},
+		{"_id", "_id"},
+		{"__v", "__v"},
+		{"_private_field", "_privateField"},
+		{"a__b", "aB"},
+		{"a___b_c", "aBC"},
+		{"trailing_", "trailing_"},
+		{"_", "_"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ToCamel(tt.in); got != tt.want {
+			t.Errorf("ToCamel(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestKeysToCamelKeepsMongoID(t *testing.T) {
+	in := map[string]interface{}{
+		"_id":     1,
+		"user_id": 2,
+		"home_address": map[string]interface{}{
+			"_id":      3,
+			"zip_code": 4,
+		},
+	}
+	want := map[string]interface{}{
+		"_id":    1,
+		"userId": 2,
+		"homeAddress": map[string]interface{}{
+			"_id":     3,
+			"zipCode": 4,
+		},
+	}
+	if got := KeysToCamel(in); !reflect.DeepEqual(got, want) {
+		t.Fatalf("KeysToCamel() = %v, want %v", got, want)
+	}
+}
+
+func TestKeysToSnakeRoundTrip(t *testing.T) {
+	in := map[string]interface{}{"_id": 1, "userId": 2}
+	if got := KeysToCamel(KeysToSnake(in)); !reflect.DeepEqual(got, in) {
+		t.Fatalf("KeysToCamel(KeysToSnake()) = %v, want %v", got, in)
+	}
+}
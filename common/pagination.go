@@ -0,0 +1,112 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxPageSize caps the page size a client can request, set it once at startup to change the default
+var MaxPageSize int64 = 100
+
+// DefaultPageSize is the page size used by ParsePagination when the request sets none
+var DefaultPageSize int64 = 20
+
+// Page describes one page of a paginated listing, Page is 1-based
+type Page struct {
+	Total    int64 `json:"total"`
+	Page     int64 `json:"page"`
+	PageSize int64 `json:"page_size"`
+}
+
+// ClampPageSize bounds a client supplied page size to [1, MaxPageSize]
+func ClampPageSize(size int64) int64 {
+	return min(max(size, 1), max(MaxPageSize, 1))
+}
+
+// Clamp bounds PageSize with ClampPageSize and Page to at least 1, so the Page returned
+// to the client holds the values actually applied; returns skip and limit for the find
+// e.g skip, limit := page.Clamp(); m.Find(&out, filter, coll, ref.WithSkip(skip), ref.WithLimit(limit))
+func (p *Page) Clamp() (skip int64, limit int64) {
+	p.PageSize = ClampPageSize(p.PageSize)
+	p.Page = max(p.Page, 1)
+	return (p.Page - 1) * p.PageSize, p.PageSize
+}
+
+// TotalPages returns the number of pages needed to list Total items
+func (p *Page) TotalPages() int64 {
+	if p.PageSize <= 0 {
+		return 0
+	}
+	return (p.Total + p.PageSize - 1) / p.PageSize
+}
+
+// ParsePagination reads page and page_size from the query, falling back to limit and offset
+// missing or malformed values get the defaults (page 1, DefaultPageSize), page_size is clamped with ClampPageSize
+// e.g ?page=3&page_size=50 = (3, 50), ?limit=10&offset=20 = (3, 10), ?page=abc = (1, 20)
+func ParsePagination(r *http.Request) (page, pageSize int64) {
+	if r == nil || r.URL == nil {
+		return 1, ClampPageSize(DefaultPageSize)
+	}
+	query := r.URL.Query()
+
+	pageSize = queryInt(query.Get("page_size"))
+	if pageSize <= 0 {
+		pageSize = queryInt(query.Get("limit"))
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	pageSize = ClampPageSize(pageSize)
+
+	page = queryInt(query.Get("page"))
+	if page <= 0 {
+		page = max(queryInt(query.Get("offset")), 0)/pageSize + 1
+	}
+	return page, pageSize
+}
+
+// queryInt parses a query param value, 0 when missing or malformed
+func queryInt(v string) int64 {
+	return int64(ParseInt(strings.TrimSpace(v)))
+}
+
+// WritePaginationHeaders sets X-Total-Count, X-Page, X-Page-Size and a Link header
+// with first/prev/next/last URLs built from the request URL (page and page_size query params)
+// call it before writing the body, e.g common.WritePaginationHeaders(w, r, &common.Page{Total: 120, Page: 2, PageSize: 20})
+func WritePaginationHeaders(w http.ResponseWriter, r *http.Request, page *Page) {
+	if page == nil {
+		return
+	}
+
+	header := w.Header()
+	header.Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	header.Set("X-Page", strconv.FormatInt(page.Page, 10))
+	header.Set("X-Page-Size", strconv.FormatInt(page.PageSize, 10))
+
+	last := page.TotalPages()
+	if r == nil || r.URL == nil || last == 0 {
+		return
+	}
+
+	links := []string{pageLink(r, 1, page.PageSize, "first")}
+	if page.Page > 1 {
+		links = append(links, pageLink(r, min(page.Page-1, last), page.PageSize, "prev"))
+	}
+	if page.Page < last {
+		links = append(links, pageLink(r, page.Page+1, page.PageSize, "next"))
+	}
+	links = append(links, pageLink(r, last, page.PageSize, "last"))
+	header.Set("Link", strings.Join(links, ", "))
+}
+
+// pageLink formats one Link header entry pointing at the given page of the current request
+func pageLink(r *http.Request, page, pageSize int64, rel string) string {
+	u := *r.URL
+	query := u.Query()
+	query.Set("page", strconv.FormatInt(page, 10))
+	query.Set("page_size", strconv.FormatInt(pageSize, 10))
+	u.RawQuery = query.Encode()
+	return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+}
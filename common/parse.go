@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"math/big"
 	"net/http"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
@@ -20,6 +23,17 @@ func Payload(output any, r *http.Request) error {
 	return nil
 }
 
+// PayloadUseNumber decodes like Payload but keeps numbers as json.Number instead of float64
+// so big integer IDs (beyond 2^53) decoded into interface{} keep their precision
+func PayloadUseNumber(output any, r *http.Request) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(output); err != nil {
+		return err
+	}
+	return nil
+}
+
 func ToJSON(v interface{}) string {
 	json, err := json.Marshal(v)
 	if err != nil {
@@ -28,6 +42,69 @@ func ToJSON(v interface{}) string {
 	return string(json)
 }
 
+// ToJSONMongo marshals Mongo results to JSON with ObjectIDs as hex strings and bson dates as RFC3339
+func ToJSONMongo(v interface{}) (string, error) {
+	bytes, err := json.Marshal(normalizeMongoValue(v))
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// normalizeMongoValue walks bson documents and arrays replacing driver types with JSON friendly values
+func normalizeMongoValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case bson.ObjectID:
+		return val.Hex()
+	case bson.DateTime:
+		return val.Time().UTC().Format(TimeFormatRFC3339)
+	case bson.M:
+		return normalizeMongoMap(val)
+	case map[string]interface{}:
+		return normalizeMongoMap(val)
+	case bson.D:
+		out := make(map[string]interface{}, len(val))
+		for _, elem := range val {
+			out[elem.Key] = normalizeMongoValue(elem.Value)
+		}
+		return out
+	case bson.A:
+		return normalizeMongoSlice(val)
+	case []interface{}:
+		return normalizeMongoSlice(val)
+	case []bson.M:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeMongoMap(item)
+		}
+		return out
+	case []bson.D:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeMongoValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func normalizeMongoMap(in map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(in))
+	for k, item := range in {
+		out[k] = normalizeMongoValue(item)
+	}
+	return out
+}
+
+func normalizeMongoSlice(in []interface{}) []interface{} {
+	out := make([]interface{}, len(in))
+	for i, item := range in {
+		out[i] = normalizeMongoValue(item)
+	}
+	return out
+}
+
 // ToString converts any value to string (optimized with strconv)
 func ParseString(v interface{}) string {
 	if v == nil {
@@ -37,6 +114,8 @@ func ParseString(v interface{}) string {
 	switch val := v.(type) {
 	case string:
 		return val
+	case json.Number:
+		return val.String()
 	case int:
 		return strconv.Itoa(val)
 	case int8:
@@ -80,6 +159,64 @@ func ParseString(v interface{}) string {
 	}
 }
 
+// ParseScalarString converts scalars (strings, numbers, bools, ObjectID, time, decimal) like ParseString
+// returns ("", false) for nil, structs, maps and slices instead of JSON-encoding them
+func ParseScalarString(v interface{}) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+
+	switch v.(type) {
+	case bson.ObjectID, bson.Decimal128, json.Number, time.Time:
+		return ParseString(v), true
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return ParseString(v), true
+	default:
+		return "", false
+	}
+}
+
+// ToStringTruncated converts v like ParseString and caps the result at maxLen bytes
+// the cut part is reported as a size suffix, e.g `[{"_id":1},...(+4KB)`, handy for debug logs of big documents
+func ToStringTruncated(v interface{}, maxLen int) string {
+	str := ParseString(v)
+	if maxLen < 0 || len(str) <= maxLen {
+		return str
+	}
+
+	// Don't cut a multi-byte character in half
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(str[cut]) {
+		cut--
+	}
+
+	var sb strings.Builder
+	sb.Grow(cut + 16)
+	sb.WriteString(str[:cut])
+	sb.WriteString("...(+")
+	sb.WriteString(byteSize(len(str) - cut))
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// byteSize formats n bytes as B, KB or MB rounded down
+func byteSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return strconv.Itoa(n>>20) + "MB"
+	case n >= 1<<10:
+		return strconv.Itoa(n>>10) + "KB"
+	default:
+		return strconv.Itoa(n) + "B"
+	}
+}
+
 func KindDataType(v interface{}) {
 	t := reflect.TypeOf(v) // returns the type
 	k := t.Kind()          // returns the kind (basic category)
@@ -102,12 +239,26 @@ func ParseInt(i interface{}) int {
 		return int(v)
 	case int64:
 		return int(v)
+	case bson.Decimal128:
+		// Truncate the decimal to an integer, 0 when it does not fit.
+		parsedInt, _ := decimal128Int64(v)
+		return int(parsedInt)
 	case float32:
 		// Truncate the float to an integer.
 		return int(v)
 	case float64:
 		// Truncate the float to an integer.
 		return int(v)
+	case json.Number:
+		if parsedInt, err := v.Int64(); err == nil {
+			return int(parsedInt)
+		}
+		// Truncate a decimal number to an integer.
+		parsedFloat, err := v.Float64()
+		if err != nil {
+			return 0
+		}
+		return int(parsedFloat)
 	case string:
 		// Attempt to parse the string into an integer.
 		parsedInt, err := strconv.Atoi(v)
@@ -120,6 +271,103 @@ func ParseInt(i interface{}) int {
 	}
 }
 
+// ParseInt64 converts v into an int64 like ParseInt, e.g for counters decoded from Mongo
+// as int32, int64, float64 or Decimal128 depending on how they were stored
+func ParseInt64(v interface{}) int64 {
+	switch val := v.(type) {
+	case int64:
+		return val
+	case int32:
+		return int64(val)
+	case bson.Decimal128:
+		parsedInt, _ := decimal128Int64(val)
+		return parsedInt
+	case string:
+		parsedInt, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0
+		}
+		return parsedInt
+	default:
+		return int64(ParseInt(v))
+	}
+}
+
+// decimal128Int64 truncates d to an int64, false for NaN, infinities and values out of range
+func decimal128Int64(d bson.Decimal128) (int64, bool) {
+	coefficient, exp, err := d.BigInt()
+	if err != nil {
+		return 0, false
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exp))), nil)
+	if exp > 0 {
+		coefficient.Mul(coefficient, scale)
+	} else if exp < 0 {
+		coefficient.Quo(coefficient, scale)
+	}
+	if !coefficient.IsInt64() {
+		return 0, false
+	}
+	return coefficient.Int64(), true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// ParseIntBase converts v into an int64 of bitSize bits, strings are parsed in the given base
+// base 0 infers it from the prefix ("0x", "0o", "0b"), a prefix matching an explicit base is also accepted
+// e.g ParseIntBase("0xFF", 16, 64) = 255, ParseIntBase("1010", 2, 8) = 10
+// returns an error when v is not an integer or does not fit in bitSize
+func ParseIntBase(v interface{}, base, bitSize int) (int64, error) {
+	switch val := v.(type) {
+	case int, int8, int16, int32, int64:
+		return fitIntBits(reflect.ValueOf(val).Int(), bitSize)
+	case uint, uint8, uint16, uint32, uint64:
+		u := reflect.ValueOf(val).Uint()
+		if u > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", u)
+		}
+		return fitIntBits(int64(u), bitSize)
+	default:
+		str := strings.TrimSpace(ParseString(v))
+		return strconv.ParseInt(trimBasePrefix(str, base), base, bitSize)
+	}
+}
+
+// fitIntBits checks that n fits in a signed integer of bitSize bits
+func fitIntBits(n int64, bitSize int) (int64, error) {
+	if bitSize <= 0 || bitSize >= 64 {
+		return n, nil
+	}
+	limit := int64(1) << (bitSize - 1)
+	if n < -limit || n >= limit {
+		return 0, fmt.Errorf("value %d overflows int%d", n, bitSize)
+	}
+	return n, nil
+}
+
+// trimBasePrefix drops a "0x"/"0o"/"0b" prefix matching base since strconv only accepts it with base 0
+func trimBasePrefix(str string, base int) string {
+	sign := ""
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		sign, str = str[:1], str[1:]
+	}
+
+	lower := strings.ToLower(str)
+	switch {
+	case base == 16 && strings.HasPrefix(lower, "0x"),
+		base == 8 && strings.HasPrefix(lower, "0o"),
+		base == 2 && strings.HasPrefix(lower, "0b"):
+		str = str[2:]
+	}
+	return sign + str
+}
+
 // ParseFloat64 converts any data type to float64 without rounding, keeping value as is
 func ParseFloat64(v interface{}) float64 {
 	if v == nil {
@@ -151,11 +399,22 @@ func ParseFloat64(v interface{}) float64 {
 		return float64(val)
 	case uint64:
 		return float64(val)
+	case bson.Decimal128:
+		// String() gives a form ParseFloat accepts, e.g "1.5E+3", "NaN", "Infinity"
+		if parsed, err := strconv.ParseFloat(val.String(), 64); err == nil {
+			return parsed
+		}
+		return 0
 	case string:
 		if parsed, err := strconv.ParseFloat(val, 64); err == nil {
 			return parsed
 		}
 		return 0
+	case json.Number:
+		if parsed, err := val.Float64(); err == nil {
+			return parsed
+		}
+		return 0
 	case bool:
 		if val {
 			return 1
@@ -171,6 +430,35 @@ func ParseFloat64(v interface{}) float64 {
 	}
 }
 
+// ParseNumber converts v to int64 when it holds an integral value and to float64 otherwise
+// e.g 30.0 from JSON becomes int64(30) so Mongo stores an integer instead of a double
+func ParseNumber(v interface{}) interface{} {
+	switch val := v.(type) {
+	case int, int8, int16, int32, int64:
+		return reflect.ValueOf(val).Int()
+	case uint, uint8, uint16, uint32, uint64:
+		u := reflect.ValueOf(val).Uint()
+		if u > math.MaxInt64 {
+			return float64(u)
+		}
+		return int64(u)
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64); err == nil {
+			return i
+		}
+	}
+
+	f := ParseFloat64(v)
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return int64(f)
+	}
+	return f
+}
+
 // ParseFloat64RoundUp rounds up to the specified number of decimal places
 func ParseFloat64RoundUp(v interface{}, decimalPlaces int) float64 {
 	value := ParseFloat64(v)
@@ -255,6 +543,9 @@ func ParseBool(v interface{}) bool {
 		// Any float type - convert to float64 for comparison
 		floatVal := reflect.ValueOf(val).Float()
 		return floatVal != 0
+	case json.Number:
+		num, err := val.Float64()
+		return err == nil && num != 0
 	case string:
 		// Normalize string: trim spaces and convert to lowercase
 		str := strings.TrimSpace(strings.ToLower(val))
@@ -291,6 +582,7 @@ func ParseBool(v interface{}) bool {
 // - int/int64: treats as Unix timestamp (seconds)
 // - float64: treats as Unix timestamp with fractional seconds
 // Returns zero time (time.Time{}) if parsing fails
+// Date-only strings like "2024-10-14" parse as midnight UTC, use ParseDate for midnight in another zone
 // ParseBool examples
 // ParseBool(true)              // true
 // ParseBool(1)                 // true
@@ -329,14 +621,10 @@ func ParseTime(v interface{}, formats ...string) time.Time {
 		return time.Unix(int64(val), 0)
 	case float32:
 		// Treat as Unix timestamp with fractional seconds
-		sec := int64(val)
-		nsec := int64((val - float32(sec)) * 1e9)
-		return time.Unix(sec, nsec)
+		return unixFromFloat(float64(val))
 	case float64:
 		// Treat as Unix timestamp with fractional seconds
-		sec := int64(val)
-		nsec := int64((val - float64(sec)) * 1e9)
-		return time.Unix(sec, nsec)
+		return unixFromFloat(val)
 	default:
 		// Try converting to string first
 		str := ParseString(v)
@@ -347,6 +635,54 @@ func ParseTime(v interface{}, formats ...string) time.Time {
 	}
 }
 
+// ParseDate parses v like ParseTime and returns midnight of its calendar date in loc (time.Local when nil)
+// strings and time.Time keep the date as written, so "2024-10-14" stays the 14th in every zone;
+// unix timestamps are an instant, their date is taken in loc
+// e.g storing birthdays and calendar dates without off-by-one-day shifts
+// ParseDate("2024-10-14", jakarta) // 2024-10-14 00:00:00 +0700 WIB
+func ParseDate(v interface{}, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	t := ParseTime(v)
+	if t.IsZero() {
+		return time.Time{}
+	}
+
+	switch v.(type) {
+	case int, int32, int64, uint, uint32, uint64, float32, float64:
+		t = t.In(loc)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// ParseTimeWithFormat works like ParseTime but also returns the format that matched
+// numeric values report TimeFormatUnix, time.Time values report an empty format
+// returns an error when v cannot be parsed
+func ParseTimeWithFormat(v interface{}, formats ...string) (time.Time, string, error) {
+	var t time.Time
+	var format string
+
+	switch val := v.(type) {
+	case time.Time, *time.Time:
+		t = ParseTime(val)
+	case int, int32, int64, uint, uint32, uint64, float32, float64:
+		t, format = ParseTime(val), TimeFormatUnix
+	case string:
+		t, format = matchTimeFormat(val, formats...)
+	default:
+		if str := ParseString(v); str != "" {
+			t, format = matchTimeFormat(str, formats...)
+		}
+	}
+
+	if t.IsZero() {
+		return time.Time{}, "", fmt.Errorf("unable to parse time from %v", v)
+	}
+	return t, format, nil
+}
+
 // parseTimeFromString attempts to parse a time string using provided formats or common formats
 // ParseTime examples - auto-detect format
 // ParseTime("2024-10-14T15:04:05Z")           // RFC3339
@@ -365,9 +701,15 @@ func ParseTime(v interface{}, formats ...string) time.Time {
 // // Multiple formats priority (tries in order)
 // common.ParseTime("10-14-2024", common.TimeFormatDateUSWithDash, common.TimeFormatDateEUWithDash)
 func parseTimeFromString(str string, formats ...string) time.Time {
+	t, _ := matchTimeFormat(str, formats...)
+	return t
+}
+
+// matchTimeFormat parses str like parseTimeFromString and also returns the format that matched
+func matchTimeFormat(str string, formats ...string) (time.Time, string) {
 	str = strings.TrimSpace(str)
 	if str == "" {
-		return time.Time{}
+		return time.Time{}, ""
 	}
 
 	// If custom formats are provided, try them first
@@ -414,34 +756,103 @@ func parseTimeFromString(str string, formats ...string) time.Time {
 
 	for _, format := range commonFormats {
 		if t, err := time.Parse(format, str); err == nil {
-			return t
+			return t, format
 		}
 	}
 
+	// ISO week and ordinal dates
+	if t, format := parseISODate(str); !t.IsZero() {
+		return t, format
+	}
+
 	// Try parsing as Unix timestamp (string)
 	if t := parseUnixTimestamp(str, ""); !t.IsZero() {
-		return t
+		return t, unixFormatOf(str)
 	}
 
-	return time.Time{}
+	return time.Time{}, ""
+}
+
+var (
+	isoWeekPattern    = regexp.MustCompile(`^(\d{4})-?W(\d{2})(?:-?([1-7]))?$`)
+	isoOrdinalPattern = regexp.MustCompile(`^(\d{4})-(\d{3})$`)
+)
+
+// parseISODate parses ISO 8601 week dates (2024-W42-1, 2024W421, 2024-W42) and ordinal dates (2024-287)
+// at midnight UTC, returns the zero time when str is neither or out of range (e.g week 53 of a 52 week year)
+func parseISODate(str string) (time.Time, string) {
+	if m := isoWeekPattern.FindStringSubmatch(str); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		week, _ := strconv.Atoi(m[2])
+		day := 1
+		if m[3] != "" {
+			day, _ = strconv.Atoi(m[3])
+		}
+
+		// Week 1 is the week holding January 4th, weeks start on Monday
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+		monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+		t := monday.AddDate(0, 0, (week-1)*7+day-1)
+		if y, w := t.ISOWeek(); week < 1 || y != year || w != week {
+			return time.Time{}, ""
+		}
+		return t, TimeFormatISOWeek
+	}
+
+	if m := isoOrdinalPattern.FindStringSubmatch(str); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		day, _ := strconv.Atoi(m[2])
+		t := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day-1)
+		if day < 1 || t.Year() != year {
+			return time.Time{}, ""
+		}
+		return t, TimeFormatISOOrdinal
+	}
+
+	return time.Time{}, ""
 }
 
-func parseCustomFormats(str string, formats ...string) time.Time {
+func parseCustomFormats(str string, formats ...string) (time.Time, string) {
 	for _, format := range formats {
+		// Handle ISO week and ordinal dates
+		if format == TimeFormatISOWeek || format == TimeFormatISOOrdinal {
+			if t, matched := parseISODate(str); matched == format {
+				return t, format
+			}
+			continue
+		}
 		// Handle special unix timestamp formats
 		if strings.HasPrefix(format, "unix") {
 			if t := parseUnixTimestamp(str, format); !t.IsZero() {
-				return t
+				return t, format
 			}
 			continue
 		}
 		// Try parsing with the provided format
 		if t, err := time.Parse(format, str); err == nil {
-			return t
+			return t, format
 		}
 	}
 	// If custom formats are provided but none worked, return zero time
-	return time.Time{}
+	return time.Time{}, ""
+}
+
+// unixFormatOf names the unix format auto-detected for a numeric string, mirroring uniqueDefaultParseTime
+func unixFormatOf(str string) string {
+	num, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return TimeFormatUnix // fractional seconds
+	}
+	switch {
+	case num > 1e18:
+		return TimeFormatUnixNano
+	case num > 1e15:
+		return TimeFormatUnixMicro
+	case num > 1e12:
+		return TimeFormatUnixMilli
+	default:
+		return TimeFormatUnix
+	}
 }
 
 // parseUnixTimestamp attempts to parse a Unix timestamp from string
@@ -470,14 +881,21 @@ func parseUnixTimestamp(str string, format string) time.Time {
 
 	// Try parsing as float for fractional seconds
 	if num, err := strconv.ParseFloat(str, 64); err == nil {
-		sec := int64(num)
-		nsec := int64((num - float64(sec)) * 1e9)
-		return time.Unix(sec, nsec)
+		return unixFromFloat(num)
 	}
 
 	return time.Time{}
 }
 
+// unixFromFloat converts a Unix timestamp with fractional seconds to time.Time
+// the fraction is rounded to the microsecond, a float64 epoch cannot hold more precision
+// and truncating would turn .123 into .122999999
+func unixFromFloat(num float64) time.Time {
+	sec := int64(num)
+	usec := int64(math.Round((num - float64(sec)) * 1e6))
+	return time.Unix(sec, usec*1e3)
+}
+
 func uniqueDefaultParseTime(num int64) time.Time {
 	if num > 1e15 { // Likely microseconds or nanoseconds
 		if num > 1e18 { // Likely nanoseconds
@@ -502,6 +920,16 @@ func ParseObjectID(v interface{}) bson.ObjectID {
 	return objectID
 }
 
+// ParseExtJSON parses a Mongo extended JSON string (e.g a filter copied from Compass) into bson.M
+// both canonical and relaxed forms are accepted, e.g {"_id": {"$oid": "..."}, "age": {"$gte": 18}}
+func ParseExtJSON(s string) (bson.M, error) {
+	var out bson.M
+	if err := bson.UnmarshalExtJSON([]byte(s), false, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func MapToStruct(in interface{}, out interface{}) error {
 	// Convert map to JSON
 	bytes, err := json.Marshal(in)
@@ -521,3 +949,36 @@ func StructToMap(in interface{}, out map[string]interface{}) error {
 
 	return json.Unmarshal(bytes, &out)
 }
+
+// StructToDotMap converts a struct (or map) to a flat map with dotted keys for nested documents, ready for a $set
+// e.g {"address": {"city": "x"}} becomes {"address.city": "x"}; keys follow the bson tags (_id stays _id)
+// and values keep their BSON types (ObjectID, DateTime, int32/int64), arrays are kept whole
+func StructToDotMap(in interface{}) (map[string]interface{}, error) {
+	data, err := bson.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	out := map[string]interface{}{}
+	flattenDocument("", doc, out)
+	return out, nil
+}
+
+func flattenDocument(prefix string, doc bson.D, out map[string]interface{}) {
+	for _, elem := range doc {
+		key := elem.Key
+		if prefix != "" {
+			key = prefix + "." + elem.Key
+		}
+
+		if child, ok := elem.Value.(bson.D); ok && len(child) > 0 {
+			flattenDocument(key, child, out)
+			continue
+		}
+		out[key] = elem.Value
+	}
+}
@@ -0,0 +1,207 @@
+package common
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUnixFromFloat(t *testing.T) {
+	tests := []struct {
+		name string
+		in   float64
+		want time.Time
+	}{
+		{"milliseconds", 1700000000.123, time.Unix(1700000000, 123e6)},
+		{"last millisecond", 1700000000.999, time.Unix(1700000000, 999e6)},
+		{"microseconds", 1700000000.123456, time.Unix(1700000000, 123456e3)},
+		{"rounds up into the next second", 1700000000.9999996, time.Unix(1700000001, 0)},
+		{"rounds down to the second", 1700000000.0000004, time.Unix(1700000000, 0)},
+		{"whole seconds", 1700000000, time.Unix(1700000000, 0)},
+		{"before the epoch", -1.5, time.Unix(-2, 5e8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unixFromFloat(tt.in); !got.Equal(tt.want) {
+				t.Fatalf("unixFromFloat(%v) = %v, want %v", tt.in, got.UTC(), tt.want.UTC())
+			}
+		})
+	}
+}
+
+func TestParseTimeFloatEpochMilliseconds(t *testing.T) {
+	for _, in := range []interface{}{1700000000.123, "1700000000.123"} {
+		if got := ParseTime(in).UnixMilli(); got != 1700000000123 {
+			t.Fatalf("ParseTime(%v).UnixMilli() = %d, want 1700000000123", in, got)
+		}
+	}
+}
+
+func TestParseTimeISOWeekAndOrdinal(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		in         string
+		want       time.Time
+		wantFormat string
+	}{
+		{"2024-W42-1", date(2024, time.October, 14), TimeFormatISOWeek},
+		{"2024W421", date(2024, time.October, 14), TimeFormatISOWeek},
+		{"2024-W42", date(2024, time.October, 14), TimeFormatISOWeek},
+		// Year boundaries: 2020 has 53 weeks and its last week ends in 2021
+		{"2020-W53", date(2020, time.December, 28), TimeFormatISOWeek},
+		{"2020-W53-5", date(2021, time.January, 1), TimeFormatISOWeek},
+		{"2020-W53-7", date(2021, time.January, 3), TimeFormatISOWeek},
+		{"2021-W01-1", date(2021, time.January, 4), TimeFormatISOWeek},
+		// Week 1 of 2025 starts in 2024
+		{"2025-W01-1", date(2024, time.December, 30), TimeFormatISOWeek},
+		{"2026-W53-7", date(2027, time.January, 3), TimeFormatISOWeek},
+		// Ordinal dates, leap and common years
+		{"2024-001", date(2024, time.January, 1), TimeFormatISOOrdinal},
+		{"2024-060", date(2024, time.February, 29), TimeFormatISOOrdinal},
+		{"2024-287", date(2024, time.October, 13), TimeFormatISOOrdinal},
+		{"2024-366", date(2024, time.December, 31), TimeFormatISOOrdinal},
+		{"2023-365", date(2023, time.December, 31), TimeFormatISOOrdinal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, format, err := ParseTimeWithFormat(tt.in)
+			if err != nil {
+				t.Fatalf("ParseTimeWithFormat(%q) error = %v", tt.in, err)
+			}
+			if !got.Equal(tt.want) || format != tt.wantFormat {
+				t.Fatalf("ParseTimeWithFormat(%q) = %v, %q, want %v, %q", tt.in, got, format, tt.want, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestParseTimeISOWeekAndOrdinalInvalid(t *testing.T) {
+	for _, in := range []string{
+		"2021-W53-1", // 2021 has 52 weeks
+		"2024-W00-1",
+		"2024-W54",
+		"2023-366", // not a leap year
+		"2024-367",
+		"2024-000",
+	} {
+		t.Run(in, func(t *testing.T) {
+			if got := ParseTime(in); !got.IsZero() {
+				t.Fatalf("ParseTime(%q) = %v, want zero time", in, got)
+			}
+		})
+	}
+}
+
+func TestParseTimeISOFormatsExplicit(t *testing.T) {
+	if got := ParseTime("2024-W42-1", TimeFormatISOWeek); !got.Equal(time.Date(2024, time.October, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ParseTime with TimeFormatISOWeek = %v", got)
+	}
+	if got := ParseTime("2024-366", TimeFormatISOOrdinal); !got.Equal(time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ParseTime with TimeFormatISOOrdinal = %v", got)
+	}
+}
+
+func TestParseNumbersFromMixedBSONStorage(t *testing.T) {
+	dec := func(s string) bson.Decimal128 {
+		d, err := bson.ParseDecimal128(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	// Decode a real document so the values carry the types the driver returns
+	data, err := bson.Marshal(bson.D{
+		{Key: "int32", Value: int32(42)},
+		{Key: "int64", Value: int64(9000000000)},
+		{Key: "double", Value: 42.9},
+		{Key: "decimal", Value: dec("42")},
+		{Key: "decimal_fraction", Value: dec("42.9")},
+		{Key: "decimal_negative", Value: dec("-2.7")},
+		{Key: "decimal_exponent", Value: dec("1.5E+3")},
+		{Key: "decimal_big", Value: dec("9000000000")},
+		{Key: "decimal_overflow", Value: dec("1E+30")},
+		{Key: "decimal_nan", Value: dec("NaN")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key       string
+		wantInt64 int64
+		wantFloat float64
+	}{
+		{"int32", 42, 42},
+		{"int64", 9000000000, 9e9},
+		{"double", 42, 42.9},
+		{"decimal", 42, 42},
+		{"decimal_fraction", 42, 42.9},
+		{"decimal_negative", -2, -2.7},
+		{"decimal_exponent", 1500, 1500},
+		{"decimal_big", 9000000000, 9e9},
+		{"decimal_overflow", 0, 1e30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			v := doc[tt.key]
+			if got := ParseInt64(v); got != tt.wantInt64 {
+				t.Fatalf("ParseInt64(%T %v) = %d, want %d", v, v, got, tt.wantInt64)
+			}
+			if got := ParseInt(v); int64(got) != tt.wantInt64 {
+				t.Fatalf("ParseInt(%T %v) = %d, want %d", v, v, got, tt.wantInt64)
+			}
+			if got := ParseFloat64(v); got != tt.wantFloat {
+				t.Fatalf("ParseFloat64(%T %v) = %v, want %v", v, v, got, tt.wantFloat)
+			}
+		})
+	}
+
+	if got := ParseInt64(doc["decimal_nan"]); got != 0 {
+		t.Fatalf("ParseInt64(NaN) = %d, want 0", got)
+	}
+	if got := ParseFloat64(doc["decimal_nan"]); !math.IsNaN(got) {
+		t.Fatalf("ParseFloat64(NaN) = %v, want NaN", got)
+	}
+}
+
+func TestStructToDotMapKeepsBSONNamesAndTypes(t *testing.T) {
+	type address struct {
+		City string `bson:"city" json:"town"`
+	}
+	type user struct {
+		ID      bson.ObjectID `bson:"_id" json:"id"`
+		Address address       `bson:"address" json:"addr"`
+		Age     int32         `bson:"age"`
+		Created time.Time     `bson:"created_at"`
+	}
+
+	id := bson.NewObjectID()
+	created := time.Date(2024, time.October, 14, 0, 0, 0, 0, time.UTC)
+	got, err := StructToDotMap(user{ID: id, Address: address{City: "Jakarta"}, Age: 30, Created: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"_id":          id,
+		"address.city": "Jakarta",
+		"age":          int32(30),
+		"created_at":   bson.NewDateTimeFromTime(created),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("StructToDotMap() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("StructToDotMap()[%q] = %T %v, want %T %v", k, got[k], got[k], v, v)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package common
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ToBSONTime converts t to a bson.DateTime normalized to UTC with millisecond precision
+// Mongo stores dates as UTC milliseconds, the original zone and sub-millisecond part are dropped
+func ToBSONTime(t time.Time) bson.DateTime {
+	return bson.NewDateTimeFromTime(t.UTC().Truncate(time.Millisecond))
+}
+
+// FromBSONTime converts a bson.DateTime back to a UTC time.Time
+func FromBSONTime(dt bson.DateTime) time.Time {
+	return dt.Time().UTC()
+}
+
+// DurationToMillis converts d to whole milliseconds, a readable and queryable way to store durations
+func DurationToMillis(d time.Duration) int64 {
+	return d.Milliseconds()
+}
+
+// MillisToDuration converts milliseconds stored by DurationToMillis back to time.Duration
+func MillisToDuration(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,197 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+// FieldError describes a single field failing validation
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// ValidationError lists every field failing validation
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		if f.Param != "" {
+			msgs = append(msgs, fmt.Sprintf("%s failed on %s=%s", f.Field, f.Rule, f.Param))
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s failed on %s", f.Field, f.Rule))
+	}
+	return "validation failed: " + strings.Join(msgs, ", ")
+}
+
+// newValidator reports fields by their json name so errors match the request body
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// PayloadValidate decodes the request body into output then validates its `validate` struct tags
+// e.g Email string `json:"email" validate:"required,email"`
+// returns *ValidationError listing the failing fields
+func PayloadValidate(output any, r *http.Request) error {
+	if err := Payload(output, r); err != nil {
+		return err
+	}
+	return Validate(output)
+}
+
+// Validate runs the `validate` struct tags of v
+func Validate(v any) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+	return toValidationError(fieldErrs)
+}
+
+// toValidationError converts validator errors, field names are reported relative to the root struct
+func toValidationError(fieldErrs validator.ValidationErrors) *ValidationError {
+	verr := &ValidationError{Fields: make([]FieldError, 0, len(fieldErrs))}
+	for _, fe := range fieldErrs {
+		// Namespace without the root struct name, e.g "address.city"
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		verr.Fields = append(verr.Fields, FieldError{
+			Field: field,
+			Rule:  fe.Tag(),
+			Param: fe.Param(),
+		})
+	}
+	return verr
+}
+
+// RequireFields reports fields of the decoded struct v that still hold their zero value,
+// fields are matched by bson tag or Go name, e.g RequireFields(&user, "email", "createdAt")
+// meant as a cheap sanity check after a DB read to catch schema drift, returns *ValidationError
+func RequireFields(v interface{}, fields ...string) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return errors.New("RequireFields: nil value")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("RequireFields: expected struct, got %s", rv.Kind())
+	}
+
+	verr := &ValidationError{}
+	for _, name := range fields {
+		fv, ok := structFieldByName(rv, name)
+		if !ok {
+			return fmt.Errorf("RequireFields: unknown field %q", name)
+		}
+		if fv.IsZero() {
+			verr.Fields = append(verr.Fields, FieldError{Field: name, Rule: "required"})
+		}
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// structFieldByName finds a field by its bson tag name first, then by its Go name
+func structFieldByName(rv reflect.Value, name string) (reflect.Value, bool) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		tag := strings.SplitN(rt.Field(i).Tag.Get("bson"), ",", 2)[0]
+		if tag == name {
+			return rv.Field(i), true
+		}
+	}
+	if _, ok := rt.FieldByName(name); ok {
+		return rv.FieldByName(name), true
+	}
+	return reflect.Value{}, false
+}
+
+// ValidationErrorMap turns a validation error into field -> message for client responses
+// e.g {"email": "must be a valid email", "age": "must be at least 18"}
+// returns nil when err is not a validation error (e.g a malformed body)
+func ValidationErrorMap(err error) map[string]string {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		var fieldErrs validator.ValidationErrors
+		if !errors.As(err, &fieldErrs) {
+			return nil
+		}
+		verr = toValidationError(fieldErrs)
+	}
+
+	out := make(map[string]string, len(verr.Fields))
+	for _, f := range verr.Fields {
+		out[f.Field] = validationMessage(f)
+	}
+	return out
+}
+
+// validationMessage describes a failed rule in plain words
+func validationMessage(f FieldError) string {
+	switch f.Rule {
+	case "required", "required_if", "required_unless", "required_with", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "numeric", "number":
+		return "must be a number"
+	case "min":
+		return "must be at least " + f.Param
+	case "max":
+		return "must be at most " + f.Param
+	case "len":
+		return "must have length " + f.Param
+	case "gt":
+		return "must be greater than " + f.Param
+	case "gte":
+		return "must be greater than or equal to " + f.Param
+	case "lt":
+		return "must be less than " + f.Param
+	case "lte":
+		return "must be less than or equal to " + f.Param
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(f.Param, " ", ", ")
+	}
+	if f.Param != "" {
+		return fmt.Sprintf("failed on %s=%s", f.Rule, f.Param)
+	}
+	return "failed on " + f.Rule
+}
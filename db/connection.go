@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// connection holds the client shared by a MongoLib and its shallow copies (Debug, WithContext)
+// so a reconnect through any of them replaces the client for all and the old one is disconnected once
+type connection struct {
+	mu       sync.RWMutex
+	dialMu   sync.Mutex // serializes reconnects
+	database *mongo.Database
+}
+
+func (c *connection) current() *mongo.Database {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.database
+}
+
+// reconnect replaces stale with a newly dialed client and disconnects stale
+// a no-op when another caller already replaced stale, so concurrent failures reconnect only once
+func (m *MongoLib) reconnect(stale *mongo.Database) error {
+	m.conn.dialMu.Lock()
+	defer m.conn.dialMu.Unlock()
+
+	if m.conn.current() != stale {
+		return nil
+	}
+
+	database, err := m.dial()
+	if err != nil {
+		return err
+	}
+
+	m.conn.mu.Lock()
+	m.conn.database = database
+	m.conn.mu.Unlock()
+	m.logger().UTC().LogInfoLevel("msg", "MongoDB connected successfully")
+
+	// Stop the pools and topology monitoring of the replaced client
+	if stale != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := stale.Client().Disconnect(ctx); err != nil && !errors.Is(err, mongo.ErrClientDisconnected) {
+			m.logger().UTC().LogWarnLevel("msg", "Failed to disconnect the replaced client:", err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/topology"
+)
+
+// lazyDial returns a dial func creating clients without contacting a server, counting the calls
+func lazyDial(t *testing.T, dials *atomic.Int32) func() (*mongo.Database, error) {
+	t.Helper()
+	return func() (*mongo.Database, error) {
+		dials.Add(1)
+		client, err := mongo.Connect(options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { client.Disconnect(context.Background()) })
+		return client.Database("gosok_test"), nil
+	}
+}
+
+func TestRetryOnDisconnectReconnectsClosedClient(t *testing.T) {
+	var dials atomic.Int32
+	m := newMongoLib()
+	m.dial = lazyDial(t, &dials)
+	if err := m.reconnect(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := m.GetClient()
+	if err := closed.Disconnect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Retry through a copy: the shared instance must see the new client too
+	clone := m.WithContext(context.Background())
+	calls := 0
+	err := clone.retryOnDisconnect(context.Background(), func() error {
+		calls++
+		if clone.GetClient() == closed {
+			return mongo.ErrClientDisconnected
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retryOnDisconnect() error = %v", err)
+	}
+	if calls != 2 || dials.Load() != 2 {
+		t.Fatalf("calls = %d, dials = %d, want 2 and 2", calls, dials.Load())
+	}
+	if m.GetClient() == closed || m.GetClient() != clone.GetClient() {
+		t.Fatal("the shared instance still uses the closed client")
+	}
+}
+
+func TestRetryOnDisconnectSkipsCancelledContext(t *testing.T) {
+	var dials atomic.Int32
+	m := newMongoLib()
+	m.dial = lazyDial(t, &dials)
+	if err := m.reconnect(nil); err != nil {
+		t.Fatal(err)
+	}
+	client := m.GetClient()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), 0)
+	defer cancelExpired()
+
+	for name, ctx := range map[string]context.Context{"cancelled": cancelled, "deadline": expired} {
+		calls := 0
+		err := m.retryOnDisconnect(ctx, func() error {
+			calls++
+			// what the driver returns when ctx ends during server selection
+			return topology.ServerSelectionError{Wrapped: ctx.Err()}
+		})
+		if err == nil || calls != 1 {
+			t.Fatalf("%s: err = %v, calls = %d, want the error and a single call", name, err, calls)
+		}
+	}
+	if dials.Load() != 1 || m.GetClient() != client {
+		t.Fatalf("dials = %d, a done context must not replace the shared client", dials.Load())
+	}
+	if isDisconnectError(topology.ServerSelectionError{Wrapped: context.Canceled}) {
+		t.Fatal("a cancelled server selection is not a disconnect")
+	}
+}
+
+func TestReconnectDisconnectsReplacedClient(t *testing.T) {
+	var dials atomic.Int32
+	m := newMongoLib()
+	m.dial = lazyDial(t, &dials)
+	if err := m.reconnect(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := m.db()
+	if err := m.reconnect(stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := stale.Client().Disconnect(context.Background()); !errors.Is(err, mongo.ErrClientDisconnected) {
+		t.Fatalf("replaced client still connected, Disconnect() error = %v", err)
+	}
+}
+
+func TestConcurrentRetriesReconnectOnce(t *testing.T) {
+	var dials atomic.Int32
+	m := newMongoLib()
+	m.dial = lazyDial(t, &dials)
+	if err := m.reconnect(nil); err != nil {
+		t.Fatal(err)
+	}
+	stale := m.GetClient()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clone := m.Debug()
+			clone.retryOnDisconnect(context.Background(), func() error {
+				if clone.GetClient() == stale {
+					return mongo.ErrClientDisconnected
+				}
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if got := dials.Load(); got != 2 {
+		t.Fatalf("dials = %d, want 2 (initial connect and a single reconnect)", got)
+	}
+}
+
+func TestReconnectAfterClose(t *testing.T) {
+	if os.Getenv("MONGO_URI") == "" || os.Getenv("MONGO_DB_NAME") == "" {
+		t.Skip("MONGO_URI and MONGO_DB_NAME are required")
+	}
+
+	m := NewMongoWithOptions()
+	if m == nil {
+		t.Fatal("NewMongoWithOptions returned nil")
+	}
+	defer m.Close()
+
+	if err := m.GetClient().Disconnect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Count("gosok_reconnect", bson.M{}); err != nil {
+		t.Fatalf("Count() after the client was closed: %v", err)
+	}
+}
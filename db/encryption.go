@@ -0,0 +1,203 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrEncryptorFuncs is returned by every read and write while a FieldEncryptor misses its Encrypt or Decrypt func
+var ErrEncryptorFuncs = errors.New("field encryptor requires Encrypt and Decrypt funcs")
+
+// FieldEncryptor lists the field paths ("ssn", "profile.phone") encrypted before writes and decrypted after reads
+// only string values are supported; Encrypt and Decrypt are required, e.g AES-GCM with a 32-byte key from a secret store
+// encrypted fields can't be queried or indexed by value, keep them out of filters and sorts
+type FieldEncryptor struct {
+	Fields  []string
+	Encrypt func(plain string) (string, error)
+	Decrypt func(cipher string) (string, error)
+}
+
+// WithFieldEncryptor encrypts enc.Fields on every write (inserts, $set and $setOnInsert of updates, BulkUpsert)
+// and decrypts them on FindOne and Find; without Encrypt and Decrypt those operations fail with ErrEncryptorFuncs
+// e.g db.NewMongoWithOptions(db.WithFieldEncryptor(db.FieldEncryptor{Fields: []string{"ssn"}, Encrypt: enc, Decrypt: dec}))
+func WithFieldEncryptor(enc FieldEncryptor) MongoOption {
+	return func(m *MongoLib) {
+		if len(enc.Fields) == 0 {
+			m.encryptor = nil
+			return
+		}
+		m.encryptor = &enc
+	}
+}
+
+// encryptFields returns a copy of document with the configured fields encrypted
+// structs are converted to bson.D so the values can be replaced
+func (m *MongoLib) encryptFields(document any) (any, error) {
+	if m.encryptor == nil {
+		return document, nil
+	}
+	doc, ok := toBSOND(document)
+	if !ok {
+		return document, nil
+	}
+	return doc, m.encryptor.transform(doc, m.encryptor.Encrypt)
+}
+
+// decryptFields decrypts the configured fields of doc in place
+func (m *MongoLib) decryptFields(doc bson.D) error {
+	return m.encryptor.transform(doc, m.encryptor.Decrypt)
+}
+
+// encryptUpdate encrypts the configured fields set by an update: $set and $setOnInsert values are encrypted,
+// other operators and pipeline stages touching an encrypted field are rejected as they would store plaintext
+func (m *MongoLib) encryptUpdate(update any) (any, error) {
+	if m.encryptor == nil {
+		return update, nil
+	}
+
+	if pipeline, ok := update.([]bson.M); ok {
+		for _, stage := range pipeline {
+			for op, fields := range stage {
+				if err := m.encryptor.reject(op, fields); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return update, nil
+	}
+
+	doc, ok := toBSOND(update)
+	if !ok {
+		return update, nil
+	}
+	for i, elem := range doc {
+		switch elem.Key {
+		case "$set", "$setOnInsert":
+			fields, ok := toBSOND(elem.Value)
+			if !ok {
+				continue
+			}
+			if err := m.encryptor.transform(fields, m.encryptor.Encrypt); err != nil {
+				return nil, err
+			}
+			doc[i].Value = fields
+		case "$unset", "$currentDate":
+			// Removing a field or stamping a date never writes an encrypted value
+		default:
+			if !strings.HasPrefix(elem.Key, "$") {
+				// Replacement document
+				return m.encryptFields(update)
+			}
+			if err := m.encryptor.reject(elem.Key, elem.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return doc, nil
+}
+
+// reject returns an error when the operator fields of op touch an encrypted field
+func (e *FieldEncryptor) reject(op string, fields any) error {
+	doc, ok := toBSOND(fields)
+	if !ok {
+		return nil
+	}
+	for _, elem := range doc {
+		for _, path := range e.Fields {
+			if elem.Key == path || strings.HasPrefix(path, elem.Key+".") || strings.HasPrefix(elem.Key, path+".") {
+				return fmt.Errorf("field %q: encrypted fields can only be written with $set or $setOnInsert, not %s", path, op)
+			}
+		}
+	}
+	return nil
+}
+
+// encrypts reports whether field is one of the encrypted fields
+func (e *FieldEncryptor) encrypts(field string) bool {
+	for _, path := range e.Fields {
+		if path == field {
+			return true
+		}
+	}
+	return false
+}
+
+// transform applies fn to every configured field of doc
+func (e *FieldEncryptor) transform(doc bson.D, fn func(string) (string, error)) error {
+	if e.Encrypt == nil || e.Decrypt == nil {
+		return ErrEncryptorFuncs
+	}
+	for _, path := range e.Fields {
+		if err := transformPath(doc, path, fn); err != nil {
+			return fmt.Errorf("field %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// transformPath walks path through nested documents, a dotted key such as a $set "profile.phone" matches as is
+// missing and nil fields are skipped
+func transformPath(doc bson.D, path string, fn func(string) (string, error)) error {
+	for i, elem := range doc {
+		if elem.Key == path {
+			value, err := transformValue(elem.Value, fn)
+			if err != nil {
+				return err
+			}
+			doc[i].Value = value
+			continue
+		}
+		if !strings.HasPrefix(path, elem.Key+".") {
+			continue
+		}
+
+		nested, ok := toBSOND(elem.Value)
+		if !ok {
+			continue
+		}
+		if err := transformPath(nested, strings.TrimPrefix(path, elem.Key+"."), fn); err != nil {
+			return err
+		}
+		doc[i].Value = nested
+	}
+	return nil
+}
+
+func transformValue(v any, fn func(string) (string, error)) (any, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return fn(val)
+	default:
+		return nil, errors.New("only string values can be encrypted")
+	}
+}
+
+// decodeDocuments decodes docs into output, a pointer to a slice, the way cursor.All would
+func decodeDocuments(docs []bson.D, output any) error {
+	ptr := reflect.ValueOf(output)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		return errors.New("output must be a pointer to a slice")
+	}
+
+	slice := ptr.Elem()
+	out := reflect.MakeSlice(slice.Type(), 0, len(docs))
+	for _, doc := range docs {
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		elem := reflect.New(slice.Type().Elem())
+		if err := bson.Unmarshal(data, elem.Interface()); err != nil {
+			return err
+		}
+		out = reflect.Append(out, elem.Elem())
+	}
+	slice.Set(out)
+	return nil
+}
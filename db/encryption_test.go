@@ -0,0 +1,154 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ranggadablues/gosok/db/ref"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// testEncryptor is a reversible stand-in for a real cipher
+func testEncryptor(fields ...string) MongoOption {
+	return WithFieldEncryptor(FieldEncryptor{
+		Fields:  fields,
+		Encrypt: func(plain string) (string, error) { return "enc:" + plain, nil },
+		Decrypt: func(cipher string) (string, error) {
+			plain, ok := strings.CutPrefix(cipher, "enc:")
+			if !ok {
+				return "", errors.New("not encrypted")
+			}
+			return plain, nil
+		},
+	})
+}
+
+// storedFields returns the fields an update writes ($set and $setOnInsert) as the stored document would hold them
+func storedFields(t *testing.T, update any) bson.D {
+	t.Helper()
+	doc, ok := toBSOND(update)
+	if !ok {
+		t.Fatalf("update %v is not a document", update)
+	}
+	var stored bson.D
+	for _, elem := range doc {
+		if elem.Key != "$set" && elem.Key != "$setOnInsert" {
+			continue
+		}
+		fields, _ := toBSOND(elem.Value)
+		stored = append(stored, fields...)
+	}
+	return stored
+}
+
+// assertRoundTrip checks that stored holds ciphertext for ssn and profile.phone and decrypts back
+func assertRoundTrip(t *testing.T, m *MongoLib, stored bson.D) {
+	t.Helper()
+	if got := lookup(stored, "ssn"); got != "enc:123-45-6789" {
+		t.Fatalf("stored ssn = %v, want ciphertext", got)
+	}
+	if err := m.decryptFields(stored); err != nil {
+		t.Fatalf("decryptFields() error = %v", err)
+	}
+	if got := lookup(stored, "ssn"); got != "123-45-6789" {
+		t.Fatalf("decrypted ssn = %v", got)
+	}
+}
+
+func lookup(doc bson.D, key string) any {
+	for _, elem := range doc {
+		if elem.Key == key {
+			return elem.Value
+		}
+	}
+	return nil
+}
+
+func TestFieldEncryptorRequiresFuncs(t *testing.T) {
+	m := newMongoLib(WithFieldEncryptor(FieldEncryptor{Fields: []string{"ssn"}}))
+
+	if _, err := m.encryptFields(bson.M{"ssn": "123-45-6789"}); !errors.Is(err, ErrEncryptorFuncs) {
+		t.Fatalf("encryptFields() error = %v, want ErrEncryptorFuncs", err)
+	}
+	if err := m.decryptFields(bson.D{{Key: "ssn", Value: "x"}}); !errors.Is(err, ErrEncryptorFuncs) {
+		t.Fatalf("decryptFields() error = %v, want ErrEncryptorFuncs", err)
+	}
+}
+
+func TestFieldEncryptionInsert(t *testing.T) {
+	m := newMongoLib(testEncryptor("ssn", "profile.phone"))
+
+	doc, err := m.encryptFields(bson.M{"name": "ann", "ssn": "123-45-6789", "profile": bson.M{"phone": "555"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := doc.(bson.D)
+	if profile, _ := toBSOND(lookup(stored, "profile")); lookup(profile, "phone") != "enc:555" {
+		t.Fatalf("nested phone not encrypted: %v", profile)
+	}
+	assertRoundTrip(t, m, stored)
+}
+
+func TestFieldEncryptionUpdateSetOnInsert(t *testing.T) {
+	m := newMongoLib(testEncryptor("ssn", "profile.phone"))
+
+	updateOpts := &ref.UpdateOptions{}
+	ref.WithSetOnInsert(bson.M{"profile.phone": "555"})(updateOpts)
+	update, err := m.prepareUpdate(ref.UpdateSet(bson.M{"ssn": "123-45-6789"}), updateOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := storedFields(t, update)
+	if got := lookup(stored, "profile.phone"); got != "enc:555" {
+		t.Fatalf("$setOnInsert phone = %v, want ciphertext", got)
+	}
+	assertRoundTrip(t, m, stored)
+}
+
+func TestFieldEncryptionUpdateIfVersion(t *testing.T) {
+	m := newMongoLib(testEncryptor("ssn"))
+
+	_, update, err := m.versionedUpdate(bson.M{"_id": 1}, 4, bson.M{"ssn": "123-45-6789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRoundTrip(t, m, storedFields(t, update))
+}
+
+func TestFieldEncryptionBulkUpsert(t *testing.T) {
+	m := newMongoLib(testEncryptor("ssn"))
+
+	models, err := m.upsertModels("sku", []bson.M{{"sku": "a", "ssn": "123-45-6789"}, {"sku": "b", "ssn": "123-45-6789"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, model := range models {
+		assertRoundTrip(t, m, storedFields(t, model.(*mongo.UpdateOneModel).Update))
+	}
+
+	if _, err := m.upsertModels("ssn", []bson.M{{"ssn": "123-45-6789"}}); err == nil {
+		t.Fatal("expected an error for an encrypted BulkUpsert key")
+	}
+}
+
+func TestFieldEncryptionRejectsPlaintextWrites(t *testing.T) {
+	m := newMongoLib(testEncryptor("ssn"))
+
+	updates := []any{
+		[]bson.M{{"$set": bson.M{"ssn": "$other"}}},
+		bson.M{"$push": bson.M{"ssn": "123"}},
+		bson.M{"$rename": bson.M{"ssn.old": "x"}},
+	}
+	for _, update := range updates {
+		if _, err := m.encryptUpdate(update); err == nil {
+			t.Fatalf("encryptUpdate(%v) accepted a plaintext write", update)
+		}
+	}
+
+	if _, err := m.encryptUpdate(bson.M{"$unset": bson.M{"ssn": ""}}); err != nil {
+		t.Fatalf("$unset rejected: %v", err)
+	}
+}
@@ -72,6 +72,7 @@ func singletonInstanceExample() {
 		log.Println("Service 1: Failed to get singleton instance")
 		return
 	}
+	defer CloseMongoInstance() // Service 1 releases its reference
 
 	// Service 2 using same singleton instance
 	service2Mongo := GetMongoInstance()
@@ -79,6 +80,7 @@ func singletonInstanceExample() {
 		log.Println("Service 2: Failed to get singleton instance")
 		return
 	}
+	defer CloseMongoInstance() // Service 2 releases its reference
 
 	// Both services share the same connection pool
 	// Total connections: 1 pool × (5-20 connections) = 5-20 connections
@@ -103,8 +105,7 @@ func singletonInstanceExample() {
 		fmt.Printf("Service 2: Found %d users\n", len(users2))
 	}
 
-	// Close singleton when application shuts down
-	// defer CloseMongoInstance() // Call this in your main() function
+	// The connection is only closed after both services released it
 }
 
 // ConnectionHealthExample demonstrates connection health checking
@@ -1,30 +1,18 @@
 package examples
 
 import (
-	"sync"
-
 	"github.com/ranggadablues/gosok/db"
 )
 
-var (
-	mongoInstance db.IMongoLib
-	mongoOnce     sync.Once
-)
-
-// GetMongoInstance returns a singleton instance of MongoDB connection
+// GetMongoInstance returns the shared MongoDB connection
 // This ensures all services share the same connection pool
+// Each call must be paired with a CloseMongoInstance
 func GetMongoInstance() db.IMongoLib {
-	mongoOnce.Do(func() {
-		mongoInstance = db.NewMongo()
-	})
-	return mongoInstance
+	return db.Acquire()
 }
 
-// CloseMongoInstance closes the singleton MongoDB connection
-// Call this during application shutdown
+// CloseMongoInstance releases the shared MongoDB connection
+// The connection is only closed once every holder has released it
 func CloseMongoInstance() error {
-	if mongoInstance != nil {
-		return mongoInstance.Close()
-	}
-	return nil
+	return db.Release()
 }
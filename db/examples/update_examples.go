@@ -298,25 +298,23 @@ func complexUpsertExample(mongoManager db.IMongoLib) {
 		fmt.Println("Successfully upserted user with complex filter")
 	}
 
-	// Example 3: Conditional upsert based on existing data
+	// Example 3: Conditional upsert, creation fields are only written on insert
 	conditionalUpdate := bson.M{
-		"$set": bson.M{
-			"name":    "Frank Wilson",
-			"age":     45,
-			"status":  "vip",
-			"updated": time.Now(),
-		},
-		"$setOnInsert": bson.M{
-			"created": time.Now(),
-			"source":  "upsert",
-		},
+		"name":    "Frank Wilson",
+		"age":     45,
+		"status":  "vip",
+		"updated": time.Now(),
 	}
 
-	err = mongoManager.UpdateOneSetPipeline(
+	err = mongoManager.UpdateOneSet(
 		"users",
 		bson.M{"email": "frank@example.com"},
 		conditionalUpdate,
 		ref.WithUpsert(true),
+		ref.WithSetOnInsert(bson.M{
+			"created": time.Now(),
+			"source":  "upsert",
+		}),
 	)
 	if err != nil {
 		log.Printf("Failed to conditional upsert: %v", err)
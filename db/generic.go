@@ -0,0 +1,19 @@
+package db
+
+import "github.com/ranggadablues/gosok/db/ref"
+
+// FindMapped runs a Find and indexes the results by key
+// e.g users, err := db.FindMapped(mongo, bson.M{}, "users", func(u User) string { return u.ID.Hex() })
+// later documents win when two results share a key
+func FindMapped[T any](m IMongoLib, filter any, collName string, key func(T) string, opts ...ref.FindOption) (map[string]T, error) {
+	var results []T
+	if err := m.Find(&results, filter, collName, opts...); err != nil {
+		return nil, err
+	}
+
+	mapped := make(map[string]T, len(results))
+	for _, result := range results {
+		mapped[key(result)] = result
+	}
+	return mapped, nil
+}
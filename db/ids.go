@@ -0,0 +1,51 @@
+package db
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// WithIDGenerator makes InsertOne and InsertMany set _id to generate() on documents without one,
+// e.g ULIDs or UUID strings instead of ObjectIDs: db.WithIDGenerator(func() any { return uuid.NewString() })
+func WithIDGenerator(generate func() any) MongoOption {
+	return func(m *MongoLib) {
+		m.idGenerator = generate
+	}
+}
+
+// assignID sets a generated _id on a copy of document unless it already has one
+// structs are converted to bson.D so the field can be added
+func (m *MongoLib) assignID(document any) any {
+	if m.idGenerator == nil {
+		return document
+	}
+
+	doc, ok := toBSOND(document)
+	if !ok {
+		return document
+	}
+	for i, elem := range doc {
+		if elem.Key != "_id" {
+			continue
+		}
+		if !isUnsetID(elem.Value) {
+			return doc
+		}
+		doc[i].Value = m.idGenerator()
+		return doc
+	}
+	return append(bson.D{{Key: "_id", Value: m.idGenerator()}}, doc...)
+}
+
+// isUnsetID reports whether an _id value is missing (nil, empty string or zero ObjectID)
+func isUnsetID(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case bson.ObjectID:
+		return val.IsZero()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,47 @@
+package db
+
+import "sync"
+
+var (
+	sharedMu       sync.Mutex
+	sharedInstance IMongoLib
+	sharedRefs     int
+)
+
+// Acquire returns the shared MongoDB connection and increments its reference count
+// the connection is created on the first call; every Acquire must be paired with a Release
+func Acquire(args ...bool) IMongoLib {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if sharedInstance == nil {
+		instance := NewMongo(args...)
+		if instance == nil {
+			return nil
+		}
+		sharedInstance = instance
+	}
+
+	sharedRefs++
+	return sharedInstance
+}
+
+// Release decrements the reference count of the shared MongoDB connection
+// the connection is only closed when the last holder releases it
+func Release() error {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if sharedInstance == nil || sharedRefs == 0 {
+		return nil
+	}
+
+	sharedRefs--
+	if sharedRefs > 0 {
+		return nil
+	}
+
+	err := sharedInstance.Close()
+	sharedInstance = nil
+	return err
+}
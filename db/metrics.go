@@ -0,0 +1,136 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+// MetricsRecorder receives operation and pool metrics from the command and pool monitors
+// plug in a Prometheus (or any other) implementation with WithMetrics
+type MetricsRecorder interface {
+	ObserveOp(name string, d time.Duration, err error)
+	SetPoolSize(n int)
+}
+
+// NoopMetrics is the default MetricsRecorder, it discards everything
+type NoopMetrics struct{}
+
+func (NoopMetrics) ObserveOp(name string, d time.Duration, err error) {}
+
+func (NoopMetrics) SetPoolSize(n int) {}
+
+// WithMetrics sets the recorder notified of every command and pool size change
+func WithMetrics(recorder MetricsRecorder) MongoOption {
+	return func(m *MongoLib) {
+		if recorder == nil {
+			recorder = NoopMetrics{}
+		}
+		m.metrics = recorder
+	}
+}
+
+// hasMetrics reports whether a real recorder was configured
+func (m *MongoLib) hasMetrics() bool {
+	_, noop := m.metrics.(NoopMetrics)
+	return !noop
+}
+
+// PoolStats is a snapshot of the connection pools, summed over every server
+type PoolStats struct {
+	Open  int `json:"open"`   // connections currently open
+	InUse int `json:"in_use"` // connections checked out by operations
+	Idle  int `json:"idle"`   // open connections waiting in the pool
+	Max   int `json:"max"`    // MaxPoolSize, applies to each server's pool
+}
+
+// poolUsage counts connections per server address, shared by the shallow copies (Debug, WithContext)
+type poolUsage struct {
+	mu        sync.Mutex
+	open      map[string]int
+	inUse     map[string]int
+	saturated map[string]bool
+}
+
+func newPoolUsage() *poolUsage {
+	return &poolUsage{
+		open:      map[string]int{},
+		inUse:     map[string]int{},
+		saturated: map[string]bool{},
+	}
+}
+
+// WithPoolSaturationWarning logs a warning once a server's pool has at least threshold
+// of MaxPoolSize connections checked out, e.g 0.9 for 90%; logged again after it drops back below
+func WithPoolSaturationWarning(threshold float64) MongoOption {
+	return func(m *MongoLib) {
+		m.saturation = threshold
+	}
+}
+
+// PoolStats returns the current open and checked out connection counts, e.g for metrics scraping
+func (m *MongoLib) PoolStats() PoolStats {
+	m.pool.mu.Lock()
+	defer m.pool.mu.Unlock()
+
+	stats := PoolStats{Max: defaultMaxPoolSize}
+	for _, n := range m.pool.open {
+		stats.Open += n
+	}
+	for _, n := range m.pool.inUse {
+		stats.InUse += n
+	}
+	stats.Idle = max(stats.Open-stats.InUse, 0)
+	return stats
+}
+
+// recordPoolEvent tracks open and checked out connections per server
+// and warns when a pool crosses the saturation threshold
+func (m *MongoLib) recordPoolEvent(evt *event.PoolEvent) {
+	m.pool.mu.Lock()
+	defer m.pool.mu.Unlock()
+
+	switch evt.Type {
+	case event.ConnectionCreated:
+		m.pool.open[evt.Address]++
+		m.metrics.SetPoolSize(m.pool.totalOpen())
+	case event.ConnectionClosed:
+		m.pool.open[evt.Address] = max(m.pool.open[evt.Address]-1, 0)
+		m.metrics.SetPoolSize(m.pool.totalOpen())
+	case event.ConnectionCheckedOut:
+		m.pool.inUse[evt.Address]++
+		m.checkSaturation(evt.Address)
+	case event.ConnectionCheckedIn:
+		m.pool.inUse[evt.Address] = max(m.pool.inUse[evt.Address]-1, 0)
+		m.checkSaturation(evt.Address)
+	}
+}
+
+// checkSaturation logs once when the pool of address crosses the threshold, the caller holds m.pool.mu
+func (m *MongoLib) checkSaturation(address string) {
+	if m.saturation <= 0 {
+		return
+	}
+
+	usage := float64(m.pool.inUse[address]) / float64(defaultMaxPoolSize)
+	saturated := usage >= m.saturation
+	if saturated == m.pool.saturated[address] {
+		return
+	}
+	m.pool.saturated[address] = saturated
+
+	if saturated {
+		print := fmt.Sprintf("[POOL] Saturated: address=%s, in use=%d/%d", address, m.pool.inUse[address], defaultMaxPoolSize)
+		m.logger().LogWarnLevel("msg", print)
+	}
+}
+
+func (p *poolUsage) totalOpen() int {
+	total := 0
+	for _, n := range p.open {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ranggadablues/gosok/db/ref"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+func TestNewMongoWithOptionsUnreachableServer(t *testing.T) {
+	// Pool events fire while the client looks for the server, they must not crash the constructor
+	t.Setenv("MONGO_URI", "mongodb://127.0.0.1:1")
+	t.Setenv("MONGO_DB_NAME", "gosok_test")
+
+	if m := NewMongoWithOptions(WithServerSelectionTimeout(300 * time.Millisecond)); m != nil {
+		t.Fatal("expected nil for an unreachable server")
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	m := newMongoLib()
+	if got := m.PoolStats(); got != (PoolStats{Max: defaultMaxPoolSize}) {
+		t.Fatalf("empty pool stats = %+v", got)
+	}
+
+	for _, typ := range []string{event.ConnectionCreated, event.ConnectionCreated, event.ConnectionCheckedOut} {
+		m.recordPoolEvent(&event.PoolEvent{Type: typ, Address: "localhost:27017"})
+	}
+
+	want := PoolStats{Open: 2, InUse: 1, Idle: 1, Max: defaultMaxPoolSize}
+	if got := m.PoolStats(); got != want {
+		t.Fatalf("PoolStats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPoolStatsAfterConnect(t *testing.T) {
+	if os.Getenv("MONGO_URI") == "" || os.Getenv("MONGO_DB_NAME") == "" {
+		t.Skip("MONGO_URI and MONGO_DB_NAME are required")
+	}
+
+	m := NewMongoWithOptions()
+	if m == nil {
+		t.Fatal("NewMongoWithOptions returned nil")
+	}
+	defer m.Close()
+
+	stats := m.PoolStats()
+	if stats.Open < 1 || stats.Max != defaultMaxPoolSize {
+		t.Fatalf("PoolStats() = %+v, want at least one open connection", stats)
+	}
+}
+
+func TestCommandMonitorInstalledOnlyWhenNeeded(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []MongoOption
+		want bool
+	}{
+		{"default", nil, false},
+		{"command logging", []MongoOption{WithCommandMonitor(true)}, true},
+		{"command capture", []MongoOption{WithCommandCapture(true)}, true},
+		{"metrics", []MongoOption{WithMetrics(countingMetrics{})}, true},
+		{"noop metrics", []MongoOption{WithMetrics(nil)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newMongoLib(tt.opts...).needsCommandMonitor(); got != tt.want {
+				t.Fatalf("needsCommandMonitor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandCaptureRequiresOption(t *testing.T) {
+	if os.Getenv("MONGO_URI") == "" || os.Getenv("MONGO_DB_NAME") == "" {
+		t.Skip("MONGO_URI and MONGO_DB_NAME are required")
+	}
+
+	m := NewMongoWithOptions()
+	if m == nil {
+		t.Fatal("NewMongoWithOptions returned nil")
+	}
+	defer m.Close()
+
+	var users []bson.M
+	err := m.Find(&users, bson.M{}, "users", ref.WithCommandCapture(func(bson.Raw) {}))
+	if !errors.Is(err, ErrCaptureDisabled) {
+		t.Fatalf("Find() error = %v, want ErrCaptureDisabled", err)
+	}
+}
+
+type countingMetrics struct{}
+
+func (countingMetrics) ObserveOp(name string, d time.Duration, err error) {}
+
+func (countingMetrics) SetPoolSize(n int) {}
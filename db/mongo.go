@@ -4,16 +4,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"time"
 
+	"github.com/ranggadablues/gosok/common"
 	"github.com/ranggadablues/gosok/db/ref"
 	"github.com/ranggadablues/gosok/logger"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/event"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
 	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/topology"
 )
 
 // IMongoLib defines the interface for MongoDB operations
@@ -23,50 +32,204 @@ type IMongoLib interface {
 	GetCollection(collName string) *mongo.Collection
 	GetDatabaseName() string
 	Debug() *MongoLib
+	WithContext(ctx context.Context) *MongoLib
 
 	// Database operations
 	FindOne(output, filter any, collName string, opts ...ref.FindOption) error
+	FindOneRaw(filter any, collName string, opts ...ref.FindOption) (bson.Raw, error)
 	Find(output, filter any, collName string, opts ...ref.FindOption) error
-	InsertOne(collName string, document any) (any, error)
-	InsertMany(collName string, documents []any) ([]any, error)
-	DeleteOne(collName string, filter any) error
-	DeleteMany(collName string, filter any) error
+	FindWithOptions(output, filter any, collName string, findOpts *ref.FindOptions, opts ...ref.FindOption) error
+	FindByIDs(output any, ids []string, collName string, opts ...ref.FindOption) error
+	Tail(collName string, filter any, out chan<- bson.M, stop <-chan struct{}) error
+	WatchDatabase(pipeline any, fn func(event bson.M) error, opts ...ref.WatchOption) error
+	InsertOne(collName string, document any, opts ...ref.WriteOption) (any, error)
+	InsertMany(collName string, documents []any, opts ...ref.WriteOption) ([]any, error)
+	BulkUpsert(collName string, keyField string, documents []bson.M) (*mongo.BulkWriteResult, error)
+	DeleteOne(collName string, filter any, opts ...ref.WriteOption) error
+	DeleteMany(collName string, filter any, opts ...ref.WriteOption) error
+	DeleteManyBatched(collName string, filter any, batchSize int) (int64, error)
 	updateOne(collName string, filter any, update any, opts ...ref.UpdateOption) error
 	UpdateOneSet(collName string, filter any, update any, opts ...ref.UpdateOption) error
 	UpdateOneSetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error
+	UpdateIfVersion(collName string, filter any, expectedVersion int64, update any) (bool, error)
 	updateMany(collName string, filter any, update any, opts ...ref.UpdateOption) error
 	UpdateManySet(collName string, filter any, update any, opts ...ref.UpdateOption) error
 	UpdateManySetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error
-	Aggregate(output, pipeline any, collName string) error
+	Aggregate(output, pipeline any, collName string, opts ...ref.AggregateOption) error
+	NextSequence(collName, name string) (int64, error)
+	Count(collName string, filter any, opts ...ref.CountOption) (int64, error)
+	AggregateOne(output, pipeline any, collName string, opts ...ref.AggregateOption) error
+	AggregateToJSON(w io.Writer, pipeline any, collName string, opts ...ref.AggregateOption) error
+	AggregateInto(pipeline any, srcColl, destColl string, mode string, opts ...ref.AggregateOption) error
+
+	// Collection operations
+	CreateCollection(name string, opts ...ref.CollectionOption) error
+	CreateTimeSeriesCollection(name, timeField, metaField string, granularity string) error
+	DropCollection(name string) error
+	ListCollections() ([]string, error)
+	CollectionExists(name string) (bool, error)
+	PoolStats() PoolStats
+	CollectionStats(collName string) (*ref.CollStats, error)
+	DropDatabase(confirmName string) error
+	CreateIndex(collName string, keys any, opts ...ref.IndexOption) (string, error)
+	EnsureTTLIndex(collName, field string, ttl time.Duration) error
+	EnsureUniqueIndex(collName string, fields bson.D) error
 }
 
 // MongoLib manages a single MongoDB connection
 type MongoLib struct {
-	uri        string
-	client     *mongo.Client
-	database   *mongo.Database
+	conn       *connection
+	dial       func() (*mongo.Database, error)
 	ctx        context.Context
 	logger     func() logger.ILogLevel
 	isdebug    bool
-	isconninfo bool
+	ispoolinfo bool
+	iscmdinfo  bool
+	iscapture  bool
+	isserver   bool
+	opTimeout  time.Duration
+	metrics    MetricsRecorder
+	pool       *poolUsage
+	saturation float64
+	appName    string
+	compress   []string
+	selTimeout time.Duration
+	heartbeat  time.Duration
+	retryWrite *bool
+
+	createdField string
+	updatedField string
+	idGenerator  func() any
+	encryptor    *FieldEncryptor
+
+	known  map[string]struct{} // strict mode allowlist, nil when disabled
+	redact map[string]struct{} // field names masked in debug logs
 }
 
-// NewMongo creates a new MongoDB connection
-// if args[0] is true, set isconninfo to true
-func NewMongo(args ...bool) IMongoLib {
-	m := &MongoLib{
-		ctx:        context.Background(),
-		logger:     logger.NewLogger,
-		isdebug:    false,
-		isconninfo: false,
+// ErrNotFound is returned when a lookup yields no document, it matches mongo.ErrNoDocuments
+var ErrNotFound = mongo.ErrNoDocuments
+
+// defaultMaxPoolSize caps the connections of each server's pool
+const defaultMaxPoolSize = 20
+
+// DefaultOperationTimeout bounds operations when the context has no deadline of its own
+const DefaultOperationTimeout = 30 * time.Second
+
+// MongoOption allows customizing the MongoDB connection
+type MongoOption func(*MongoLib)
+
+// WithConnInfo logs pool and command events when enabled
+func WithConnInfo(enabled bool) MongoOption {
+	return func(m *MongoLib) {
+		m.ispoolinfo = enabled
+		m.iscmdinfo = enabled
+	}
+}
+
+// WithPoolMonitor logs connection pool events when enabled
+func WithPoolMonitor(enabled bool) MongoOption {
+	return func(m *MongoLib) {
+		m.ispoolinfo = enabled
+	}
+}
+
+// WithCommandMonitor logs command (query) events when enabled
+func WithCommandMonitor(enabled bool) MongoOption {
+	return func(m *MongoLib) {
+		m.iscmdinfo = enabled
+	}
+}
+
+// WithCommandCapture installs the command monitor ref.WithCommandCapture relies on
+// off by default so clients not debugging queries skip the per-command monitoring overhead
+func WithCommandCapture(enabled bool) MongoOption {
+	return func(m *MongoLib) {
+		m.iscapture = enabled
+	}
+}
+
+// WithServerMonitor logs topology changes at info level when enabled,
+// e.g primary elections and servers being added or removed
+func WithServerMonitor(enabled bool) MongoOption {
+	return func(m *MongoLib) {
+		m.isserver = enabled
+	}
+}
+
+// WithHeartbeatInterval sets how often the driver checks each server's state,
+// a shorter interval notices failovers sooner at the cost of extra traffic; the driver defaults to 10s
+func WithHeartbeatInterval(d time.Duration) MongoOption {
+	return func(m *MongoLib) {
+		m.heartbeat = d
+	}
+}
+
+// WithAppName sets the name reported to MongoDB (connection logs, currentOp)
+// it takes precedence over the MONGO_APP_NAME environment variable
+func WithAppName(name string) MongoOption {
+	return func(m *MongoLib) {
+		m.appName = name
+	}
+}
+
+// WithCompressors enables wire protocol compression, e.g []string{"zstd", "snappy"}
+// compression trades CPU for network savings, worth it for cross-region links; off by default
+func WithCompressors(compressors []string) MongoOption {
+	return func(m *MongoLib) {
+		m.compress = compressors
+	}
+}
+
+// WithServerSelectionTimeout bounds how long an operation waits for a suitable server (driver default 30s)
+// e.g a web service may use 3s to fail fast with a 503 when the primary is unreachable
+func WithServerSelectionTimeout(d time.Duration) MongoOption {
+	return func(m *MongoLib) {
+		m.selTimeout = d
+	}
+}
+
+// WithRetryWrites lets the driver retry a write once after a network error or failover (on by default)
+// disable it for servers that don't support retryable writes, e.g standalone mongod or older versions
+func WithRetryWrites(enabled bool) MongoOption {
+	return func(m *MongoLib) {
+		m.retryWrite = &enabled
+	}
+}
+
+// WithOperationTimeout sets the timeout applied to each operation, 0 or less disables it
+func WithOperationTimeout(d time.Duration) MongoOption {
+	return func(m *MongoLib) {
+		m.opTimeout = d
+	}
+}
+
+// WithErrorSampling logs only 1 of every n identical error lines within window for this MongoLib,
+// the sampler is shared by every logger the library creates, see logger.NewErrorSampler
+func WithErrorSampling(n int, window time.Duration) MongoOption {
+	return func(m *MongoLib) {
+		sampler := logger.NewErrorSampler(n, window)
+		base := m.logger
+		m.logger = func() logger.ILogLevel {
+			return base().WithSampler(sampler)
+		}
 	}
+}
 
+// NewMongo creates a new MongoDB connection
+// if args[0] is true, pool and command events are logged
+func NewMongo(args ...bool) IMongoLib {
 	if len(args) > 0 {
-		m.isconninfo = args[0]
+		return NewMongoWithOptions(WithConnInfo(args[0]))
 	}
+	return NewMongoWithOptions()
+}
+
+// NewMongoWithOptions creates a new MongoDB connection customized by opts
+func NewMongoWithOptions(opts ...MongoOption) IMongoLib {
+	m := newMongoLib(opts...)
 
 	// Connect to MongoDB
-	err := m.connect()
+	err := m.reconnect(nil)
 	if err != nil {
 		m.logger().LogErrorLevel("msg", "error connecting to MongoDB:", err.Error())
 		return nil
@@ -75,38 +238,93 @@ func NewMongo(args ...bool) IMongoLib {
 	return m
 }
 
-// connect establishes a connection to MongoDB
-func (m *MongoLib) connect() error {
+// newMongoLib builds an unconnected MongoLib with its defaults and opts applied
+func newMongoLib(opts ...MongoOption) *MongoLib {
+	m := &MongoLib{
+		ctx:       context.Background(),
+		logger:    logger.NewLogger,
+		isdebug:   false,
+		opTimeout: DefaultOperationTimeout,
+		metrics:   NoopMetrics{},
+		pool:      newPoolUsage(),
+		conn:      &connection{},
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	// Bound to the original instance: monitors of a client dialed through a copy still report here
+	m.dial = m.dialDatabase
+	return m
+}
+
+// dialDatabase connects a new client to MongoDB and returns its database, the client is left unused on failure
+func (m *MongoLib) dialDatabase() (*mongo.Database, error) {
 	// Get MongoDB URI from environment
-	m.uri = os.Getenv("MONGO_URI")
-	if m.uri == "" {
-		return errors.New("MONGO_URI environment variable is required")
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		return nil, errors.New("MONGO_URI environment variable is required")
 	}
 
 	// Get database name from environment
 	dbName := os.Getenv("MONGO_DB_NAME")
 	if dbName == "" {
-		return errors.New("MONGO_DB_NAME environment variable is required")
+		return nil, errors.New("MONGO_DB_NAME environment variable is required")
+	}
+
+	// Get app name from option, environment or binary name
+	appName := m.appName
+	if appName == "" {
+		appName = os.Getenv("MONGO_APP_NAME")
+	}
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
 	}
 
 	// Configure client options with basic settings
 	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
 	clientOpts := options.Client().
-		ApplyURI(m.uri).
-		SetMaxPoolSize(20).
+		ApplyURI(uri).
+		SetAppName(appName).
+		SetMaxPoolSize(defaultMaxPoolSize).
 		SetMinPoolSize(5).
 		SetMaxConnIdleTime(5 * time.Minute).
 		SetServerAPIOptions(serverAPI)
 
-	if m.isconninfo {
-		clientOpts.SetPoolMonitor(m.setPoolMonitor())
+	if len(m.compress) > 0 {
+		clientOpts.SetCompressors(m.compress)
+	}
+
+	if m.selTimeout > 0 {
+		clientOpts.SetServerSelectionTimeout(m.selTimeout)
+	}
+
+	if m.retryWrite != nil {
+		clientOpts.SetRetryWrites(*m.retryWrite)
+	}
+
+	if m.heartbeat > 0 {
+		clientOpts.SetHeartbeatInterval(m.heartbeat)
+	}
+
+	// Pool events always feed PoolStats, logging is gated in the monitor
+	clientOpts.SetPoolMonitor(m.setPoolMonitor())
+
+	// Command events serve logging, metrics and command capture, logging is gated in the monitor
+	if m.needsCommandMonitor() {
 		clientOpts.SetMonitor(m.setMonitor())
 	}
 
+	if m.isserver {
+		clientOpts.SetServerMonitor(m.setServerMonitor())
+	}
+
 	// Connect to MongoDB
 	client, err := mongo.Connect(clientOpts)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Verify connection with ping
@@ -114,21 +332,23 @@ func (m *MongoLib) connect() error {
 	defer cancel()
 
 	if err := client.Ping(ctx, readpref.Primary()); err != nil {
-		return err
+		// Stop the topology monitoring of the unusable client
+		client.Disconnect(context.Background())
+		return nil, err
 	}
 
-	// Store client and database
-	m.client = client
-	m.database = client.Database(dbName)
-	m.logger().UTC().LogInfoLevel("msg", "MongoDB connected successfully")
-
-	return nil
+	return client.Database(dbName), nil
 }
 
 func (m *MongoLib) setPoolMonitor() *event.PoolMonitor {
 	// Monitor pool connections
 	poolMonitor := &event.PoolMonitor{
 		Event: func(evt *event.PoolEvent) {
+			m.recordPoolEvent(evt)
+			if !m.ispoolinfo {
+				return
+			}
+
 			switch evt.Type {
 			case event.ConnectionCreated:
 				print := fmt.Sprintf("[POOL] Connection created: id=%d, address=%s", evt.ConnectionID, evt.Address)
@@ -152,18 +372,38 @@ func (m *MongoLib) setPoolMonitor() *event.PoolMonitor {
 	return poolMonitor
 }
 
+// needsCommandMonitor reports whether command logging, metrics or command capture is enabled
+func (m *MongoLib) needsCommandMonitor() bool {
+	return m.iscmdinfo || m.iscapture || m.hasMetrics()
+}
+
 func (m *MongoLib) setMonitor() *event.CommandMonitor {
 	// Monitor commands (queries)
 	cmdMonitor := &event.CommandMonitor{
-		Started: func(_ context.Context, evt *event.CommandStartedEvent) {
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			if capture, ok := ctx.Value(commandCaptureKey{}).(func(bson.Raw)); ok {
+				// The driver may reuse the command buffer once the callback returns
+				capture(append(bson.Raw(nil), evt.Command...))
+			}
+			if !m.iscmdinfo {
+				return
+			}
 			print := fmt.Sprintf("[QUERY] %s on %s cmd=%v", evt.CommandName, evt.DatabaseName, evt.Command)
 			m.logger().LogInfoLevel("msg", print)
 		},
 		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			m.metrics.ObserveOp(evt.CommandName, evt.Duration, nil)
+			if !m.iscmdinfo {
+				return
+			}
 			print := fmt.Sprintf("[QUERY] Done %s (%dms)", evt.CommandName, evt.Duration.Milliseconds())
 			m.logger().LogInfoLevel("msg", print)
 		},
 		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			m.metrics.ObserveOp(evt.CommandName, evt.Duration, evt.Failure)
+			if !m.iscmdinfo {
+				return
+			}
 			print := fmt.Sprintf("[QUERY] FAIL %s (%v)", evt.CommandName, evt.Failure)
 			m.logger().LogInfoLevel("msg", print)
 		},
@@ -172,31 +412,134 @@ func (m *MongoLib) setMonitor() *event.CommandMonitor {
 	return cmdMonitor
 }
 
+func (m *MongoLib) setServerMonitor() *event.ServerMonitor {
+	// Monitor topology (failovers, servers added or removed)
+	serverMonitor := &event.ServerMonitor{
+		ServerOpening: func(evt *event.ServerOpeningEvent) {
+			print := fmt.Sprintf("[SERVER] Server added: address=%s", evt.Address)
+			m.logger().LogInfoLevel("msg", print)
+		},
+		ServerClosed: func(evt *event.ServerClosedEvent) {
+			print := fmt.Sprintf("[SERVER] Server removed: address=%s", evt.Address)
+			m.logger().LogInfoLevel("msg", print)
+		},
+		ServerDescriptionChanged: func(evt *event.ServerDescriptionChangedEvent) {
+			if evt.PreviousDescription.Kind == evt.NewDescription.Kind {
+				return
+			}
+			print := fmt.Sprintf("[SERVER] State changed: address=%s, %s -> %s",
+				evt.Address, evt.PreviousDescription.Kind, evt.NewDescription.Kind)
+			m.logger().LogInfoLevel("msg", print)
+		},
+		TopologyDescriptionChanged: func(evt *event.TopologyDescriptionChangedEvent) {
+			prev, next := topologyPrimary(evt.PreviousDescription), topologyPrimary(evt.NewDescription)
+			if prev == next {
+				return
+			}
+			print := fmt.Sprintf("[SERVER] Primary changed: %q -> %q, set=%s", prev, next, evt.NewDescription.SetName)
+			m.logger().LogInfoLevel("msg", print)
+		},
+	}
+
+	return serverMonitor
+}
+
+// topologyPrimary returns the address of the replica set primary, empty when there is none
+func topologyPrimary(desc event.TopologyDescription) string {
+	for _, server := range desc.Servers {
+		if server.Kind == "RSPrimary" {
+			return server.Addr.String()
+		}
+	}
+	return ""
+}
+
+// ErrCaptureDisabled is returned by operations using ref.WithCommandCapture on a client built without WithCommandCapture(true)
+var ErrCaptureDisabled = errors.New("command capture requires the db.WithCommandCapture(true) option")
+
+// commandCaptureKey carries the WithCommandCapture callback of an operation to the command monitor
+type commandCaptureKey struct{}
+
+// withCommandCapture returns ctx carrying fn, called for the first command the operation sends only
+func withCommandCapture(ctx context.Context, fn func(cmd bson.Raw)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	var once sync.Once
+	return context.WithValue(ctx, commandCaptureKey{}, func(cmd bson.Raw) {
+		once.Do(func() { fn(cmd) })
+	})
+}
+
 // GetClient returns the MongoDB client
 func (m *MongoLib) GetClient() *mongo.Client {
-	return m.client
+	database := m.conn.current()
+	if database == nil {
+		return nil
+	}
+	return database.Client()
+}
+
+// db returns the database of the current client, shared with the copies of m
+func (m *MongoLib) db() *mongo.Database {
+	return m.conn.current()
 }
 
 // GetCollection returns a MongoDB collection
 func (m *MongoLib) GetCollection(collName string) *mongo.Collection {
-	return m.database.Collection(collName)
+	return m.db().Collection(collName)
+}
+
+// collection returns a collection using rc as read concern and rp as read preference when set
+func (m *MongoLib) collection(collName string, rc *readconcern.ReadConcern, rp *readpref.ReadPref) *mongo.Collection {
+	if rc == nil && rp == nil {
+		return m.GetCollection(collName)
+	}
+	collOpts := options.Collection()
+	if rc != nil {
+		collOpts.SetReadConcern(rc)
+	}
+	if rp != nil {
+		collOpts.SetReadPreference(rp)
+	}
+	return m.db().Collection(collName, collOpts)
+}
+
+// writeCollection returns a collection sending writes with w:0 when unacknowledged
+func (m *MongoLib) writeCollection(collName string, unacknowledged bool) *mongo.Collection {
+	if !unacknowledged {
+		return m.GetCollection(collName)
+	}
+	return m.db().Collection(collName, options.Collection().SetWriteConcern(writeconcern.Unacknowledged()))
+}
+
+// parseWriteOptions applies insert and delete options
+func parseWriteOptions(opts []ref.WriteOption) *ref.WriteOptions {
+	writeOpts := &ref.WriteOptions{
+		Unacknowledged: false,
+	}
+	for _, opt := range opts {
+		opt(writeOpts)
+	}
+	return writeOpts
 }
 
 // GetDatabase returns a MongoDB database
 func (m *MongoLib) GetDatabaseName() string {
-	return m.database.Name()
+	return m.db().Name()
 }
 
 // Close disconnects the MongoDB client
 func (m *MongoLib) Close() error {
-	if m.client == nil {
+	client := m.GetClient()
+	if client == nil {
 		return nil
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := m.client.Disconnect(ctx); err != nil {
+	if err := client.Disconnect(ctx); err != nil {
 		m.logger().LogErrorLevel("msg", "Failed to disconnect from MongoDB:", err.Error())
 		return err
 	}
@@ -205,18 +548,37 @@ func (m *MongoLib) Close() error {
 	return nil
 }
 
+// FindOneRaw works like FindOne but returns the undecoded document
+// e.g to pass it through as JSON with bson.Raw.String() or decode it lazily with bson.Unmarshal
+func (m *MongoLib) FindOneRaw(filter any, collName string, opts ...ref.FindOption) (bson.Raw, error) {
+	var raw bson.Raw
+	if err := m.FindOne(&raw, filter, collName, opts...); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
 // FindOne finds a single document in the specified collection
 func (m *MongoLib) FindOne(output, filter any, collName string, opts ...ref.FindOption) error {
 	if err := m.ensureConnection(); err != nil {
 		return err
 	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
 
 	// Parse find options
 	findOpts := &ref.FindOptions{
-		Limit:      nil,
-		Skip:       nil,
-		Sort:       nil,
-		Projection: nil,
+		Limit:       nil,
+		Skip:        nil,
+		Sort:        nil,
+		Projection:  nil,
+		Timing:      nil,
+		ReadConcern: nil,
+		ReadPref:    nil,
 	}
 
 	// Apply options
@@ -224,9 +586,18 @@ func (m *MongoLib) FindOne(output, filter any, collName string, opts ...ref.Find
 		opt(findOpts)
 	}
 
-	// Get collection
-	collection := m.GetCollection(collName)
+	if findOpts.CommandCapture != nil && !m.iscapture {
+		return ErrCaptureDisabled
+	}
+	ctx = withCommandCapture(ctx, findOpts.CommandCapture)
+
+	// Report execution duration once the operation completes
+	if findOpts.Timing != nil {
+		start := time.Now()
+		defer func() { findOpts.Timing(time.Since(start)) }()
+	}
 
+	// Get collection
 	// Build MongoDB find options
 	mongoOpts := options.FindOne()
 	if findOpts.Sort != nil {
@@ -238,32 +609,77 @@ func (m *MongoLib) FindOne(output, filter any, collName string, opts ...ref.Find
 	if findOpts.Skip != nil {
 		mongoOpts.SetSkip(*findOpts.Skip)
 	}
+	if findOpts.CorrelationID != "" {
+		mongoOpts.SetComment(findOpts.CorrelationID)
+	}
+
+	// Encrypted fields are decoded as bson.D first to be decrypted
+	target := output
+	var encrypted bson.D
+	if m.encryptor != nil {
+		target = &encrypted
+	}
 
 	// Execute FindOne with options
-	err := collection.FindOne(m.ctx, filter, mongoOpts).Decode(output)
+	err := m.retryOnDisconnect(ctx, func() error {
+		return m.collection(collName, findOpts.ReadConcern, findOpts.ReadPref).FindOne(ctx, filter, mongoOpts).Decode(target)
+	})
 	if err != nil {
 		return err
 	}
+	if m.encryptor != nil {
+		if err := m.decryptFields(encrypted); err != nil {
+			return err
+		}
+		data, err := bson.Marshal(encrypted)
+		if err != nil {
+			return err
+		}
+		if err := bson.Unmarshal(data, output); err != nil {
+			return err
+		}
+	}
 
 	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("FindOne")
+		m.logger().UTC().LogDebugLevelWithCaller("FindOne",
+			"filter", m.debugJSON(filter),
+			"sort", m.debugJSON(findOpts.Sort),
+			"projection", m.debugJSON(findOpts.Projection),
+			"skip", m.debugJSON(findOpts.Skip),
+			"correlation_id", findOpts.CorrelationID,
+		)
 	}
 
 	return nil
 }
 
+// FindWithOptions runs Find with a prebuilt option set (see ref.NewFindOptions), opts override it
+// e.g m.FindWithOptions(&users, filter, "users", pageProfile, ref.WithSkip(40))
+func (m *MongoLib) FindWithOptions(output, filter any, collName string, findOpts *ref.FindOptions, opts ...ref.FindOption) error {
+	return m.Find(output, filter, collName, append([]ref.FindOption{ref.WithFindOptions(findOpts)}, opts...)...)
+}
+
 // Find finds multiple documents in the specified collection
 func (m *MongoLib) Find(output, filter any, collName string, opts ...ref.FindOption) error {
 	if err := m.ensureConnection(); err != nil {
 		return err
 	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
 
 	// Parse find options
 	findOpts := &ref.FindOptions{
-		Limit:      nil,
-		Skip:       nil,
-		Sort:       nil,
-		Projection: nil,
+		Limit:       nil,
+		Skip:        nil,
+		Sort:        nil,
+		Projection:  nil,
+		Timing:      nil,
+		ReadConcern: nil,
+		ReadPref:    nil,
 	}
 
 	// Apply options
@@ -271,9 +687,18 @@ func (m *MongoLib) Find(output, filter any, collName string, opts ...ref.FindOpt
 		opt(findOpts)
 	}
 
-	// Get collection
-	collection := m.GetCollection(collName)
+	if findOpts.CommandCapture != nil && !m.iscapture {
+		return ErrCaptureDisabled
+	}
+	ctx = withCommandCapture(ctx, findOpts.CommandCapture)
+
+	// Report execution duration once the operation completes
+	if findOpts.Timing != nil {
+		start := time.Now()
+		defer func() { findOpts.Timing(time.Since(start)) }()
+	}
 
+	// Get collection
 	// Build MongoDB find options
 	mongoOpts := options.Find()
 	if findOpts.Limit != nil {
@@ -282,6 +707,9 @@ func (m *MongoLib) Find(output, filter any, collName string, opts ...ref.FindOpt
 	if findOpts.Skip != nil {
 		mongoOpts.SetSkip(*findOpts.Skip)
 	}
+	if findOpts.CorrelationID != "" {
+		mongoOpts.SetComment(findOpts.CorrelationID)
+	}
 	if findOpts.Sort != nil {
 		mongoOpts.SetSort(findOpts.Sort)
 	}
@@ -290,261 +718,1689 @@ func (m *MongoLib) Find(output, filter any, collName string, opts ...ref.FindOpt
 	}
 
 	// Execute find with options
-	cursor, err := collection.Find(m.ctx, filter, mongoOpts)
+	var cursor *mongo.Cursor
+	err := m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		cursor, opErr = m.collection(collName, findOpts.ReadConcern, findOpts.ReadPref).Find(ctx, filter, mongoOpts)
+		return opErr
+	})
 	if err != nil {
 		return err
 	}
-	defer cursor.Close(m.ctx)
-
-	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("FindMany")
-	}
+	defer cursor.Close(ctx)
 
-	return cursor.All(m.ctx, output)
-}
-
-// InsertOne inserts a single document into the specified collection
-func (m *MongoLib) InsertOne(collName string, document any) (any, error) {
-	if err := m.ensureConnection(); err != nil {
-		return bson.NilObjectID, err
-	}
-	collection := m.GetCollection(collName)
-	result, err := collection.InsertOne(m.ctx, document)
-	if err != nil {
-		return bson.NilObjectID, err
-	}
-	if !result.Acknowledged {
-		return bson.NilObjectID, errors.New("insert not acknowledged")
+	if m.encryptor != nil {
+		var docs []bson.D
+		if err := cursor.All(ctx, &docs); err != nil {
+			return wrapTimeout(err)
+		}
+		for _, doc := range docs {
+			if err := m.decryptFields(doc); err != nil {
+				return err
+			}
+		}
+		if err := decodeDocuments(docs, output); err != nil {
+			return err
+		}
+	} else if err := cursor.All(ctx, output); err != nil {
+		return wrapTimeout(err)
 	}
 
 	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("InsertOne")
+		m.logger().UTC().LogDebugLevelWithCaller("FindMany",
+			"filter", m.debugJSON(filter),
+			"sort", m.debugJSON(findOpts.Sort),
+			"projection", m.debugJSON(findOpts.Projection),
+			"limit", m.debugJSON(findOpts.Limit),
+			"skip", m.debugJSON(findOpts.Skip),
+			"correlation_id", findOpts.CorrelationID,
+			"count", resultLen(output),
+		)
 	}
 
-	return result.InsertedID, nil
+	return nil
 }
 
-// InsertMany inserts multiple documents into the specified collection
-func (m *MongoLib) InsertMany(collName string, documents []any) ([]any, error) {
-	if err := m.ensureConnection(); err != nil {
-		return nil, err
-	}
-	collection := m.GetCollection(collName)
-	result, err := collection.InsertMany(m.ctx, documents)
-	if err != nil {
-		return nil, err
-	}
-	if !result.Acknowledged {
-		return nil, errors.New("insert not acknowledged")
+// FindByIDs finds the documents whose _id is one of the hex ids with a single $in query
+// invalid ids are skipped unless ref.WithStrictIDs(true) is given
+// e.g db.collectionName.find({_id: {$in: [ObjectId("..."), ObjectId("...")]}})
+func (m *MongoLib) FindByIDs(output any, ids []string, collName string, opts ...ref.FindOption) error {
+	findOpts := &ref.FindOptions{}
+	for _, opt := range opts {
+		opt(findOpts)
 	}
 
-	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("InsertMany")
+	objectIDs := make([]bson.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objectID, err := bson.ObjectIDFromHex(id)
+		if err != nil {
+			if findOpts.StrictIDs {
+				return fmt.Errorf("invalid id %q: %w", id, err)
+			}
+			continue
+		}
+		objectIDs = append(objectIDs, objectID)
 	}
 
-	return result.InsertedIDs, nil
+	return m.Find(output, bson.M{"_id": bson.M{"$in": objectIDs}}, collName, opts...)
 }
 
-// DeleteOne deletes a single document from the specified collection
-func (m *MongoLib) DeleteOne(collName string, filter any) error {
+// Tail streams documents of a capped collection into out as they are inserted, until stop is closed
+// it uses a tailable awaitData cursor and reopens it after the last streamed _id when the server closes it
+// returns nil once stopped, or the context error when the base context is cancelled; out is not closed
+func (m *MongoLib) Tail(collName string, filter any, out chan<- bson.M, stop <-chan struct{}) error {
 	if err := m.ensureConnection(); err != nil {
 		return err
 	}
-	collection := m.GetCollection(collName)
-	result, err := collection.DeleteOne(m.ctx, filter)
-	if err != nil {
+	if err := m.checkCollections(collName); err != nil {
 		return err
 	}
-	if !result.Acknowledged {
-		return errors.New("delete not acknowledged")
+
+	// Long-lived stream: cancelled by stop, not by the operation timeout
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// stopped tells a stop request apart from a cancelled base context
+	stopped := func() error {
+		return wrapTimeout(m.ctx.Err())
 	}
 
+	mongoOpts := options.Find().
+		SetCursorType(options.TailableAwait).
+		SetMaxAwaitTime(time.Second)
+
 	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("DeleteOne")
+		m.logger().UTC().LogDebugLevelWithCaller("Tail", "filter", m.debugJSON(filter))
 	}
 
-	return nil
+	var lastID any
+	for {
+		query := resumeFilter(filter, lastID)
+		cursor, err := m.GetCollection(collName).Find(ctx, query, mongoOpts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return stopped()
+			}
+			return wrapTimeout(err)
+		}
+
+		err = streamCursor(ctx, cursor, out, &lastID)
+		cursor.Close(context.Background())
+		if ctx.Err() != nil {
+			return stopped()
+		}
+		if err != nil {
+			return wrapTimeout(err)
+		}
+
+		// Cursor closed by the server, wait a bit before reopening it
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return stopped()
+		}
+	}
 }
 
-// DeleteMany deletes multiple documents from the specified collection
-func (m *MongoLib) DeleteMany(collName string, filter any) error {
-	if err := m.ensureConnection(); err != nil {
-		return err
-	}
-	collection := m.GetCollection(collName)
-	result, err := collection.DeleteMany(m.ctx, filter)
-	if err != nil {
-		return err
-	}
-	if !result.Acknowledged {
-		return errors.New("delete not acknowledged")
+// resumeFilter returns the Tail query: filter (nil or empty matches everything) restricted
+// to documents after lastID once a document was streamed
+func resumeFilter(filter, lastID any) any {
+	empty := filter == nil
+	switch f := filter.(type) {
+	case bson.M:
+		empty = len(f) == 0
+	case bson.D:
+		empty = len(f) == 0
+	case map[string]any:
+		empty = len(f) == 0
 	}
 
-	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("DeleteMany")
+	if lastID == nil {
+		if empty {
+			return bson.M{}
+		}
+		return filter
 	}
 
-	return nil
-}
-
-// UpdateOneSet(collName string, filter any, update any, opts ...ref.UpdateOption) error
-// e.g db.collectionName.update({_id: "123"}, {$set: {name: "John"}})
-func (m *MongoLib) UpdateOneSet(collName string, filter any, update any, opts ...ref.UpdateOption) error {
-	return m.updateOne(collName, filter, ref.UpdateSet(update), opts...)
+	// Resume after the last streamed document when reopening
+	after := bson.M{"_id": bson.M{"$gt": lastID}}
+	if empty {
+		return after
+	}
+	return bson.M{"$and": bson.A{filter, after}}
 }
 
-// UpdateOneSetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error
-// e.g db.collectionName.update({_id: "123"}, [{$set: {name: "$otherfield"}}])
-func (m *MongoLib) UpdateOneSetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error {
-	return m.updateOne(collName, filter, ref.UpdateSetPipeline(update), opts...)
+// streamCursor sends the documents of cursor to out until it is exhausted, recording the last _id in lastID
+// it checks ctx between documents and returns the context error once ctx is done
+func streamCursor(ctx context.Context, cursor *mongo.Cursor, out chan<- bson.M, lastID *any) error {
+	for cursor.Next(ctx) {
+		// Stop promptly between documents
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		*lastID = doc["_id"]
+		select {
+		case out <- doc:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cursor.Err()
 }
 
-// UpdateOne updates a single document in the specified collection
-func (m *MongoLib) updateOne(collName string, filter any, update any, opts ...ref.UpdateOption) error {
+// WatchDatabase opens a change stream on the whole database and calls fn for every event
+// each event gets a "collection" field (from ns.coll) so fn can route it; requires a replica set
+// runs until fn returns an error, which is returned, or the base context (see WithContext) is cancelled
+// e.g db.watch([{$match: {operationType: "insert"}}], {fullDocument: "updateLookup"})
+func (m *MongoLib) WatchDatabase(pipeline any, fn func(event bson.M) error, opts ...ref.WatchOption) error {
 	if err := m.ensureConnection(); err != nil {
 		return err
 	}
 
-	// Parse update options
-	updateOpts := &ref.UpdateOptions{
-		Upsert: nil,
+	// Parse watch options
+	watchOpts := &ref.WatchOptions{
+		FullDocument: false,
+		ResumeAfter:  nil,
+		BatchSize:    nil,
+		MaxAwaitTime: nil,
 	}
 
 	// Apply options
 	for _, opt := range opts {
-		opt(updateOpts)
+		opt(watchOpts)
 	}
 
-	collection := m.GetCollection(collName)
+	// Build MongoDB change stream options
+	mongoOpts := options.ChangeStream()
+	if watchOpts.FullDocument {
+		mongoOpts.SetFullDocument(options.UpdateLookup)
+	}
+	if watchOpts.ResumeAfter != nil {
+		mongoOpts.SetResumeAfter(watchOpts.ResumeAfter)
+	}
+	if watchOpts.BatchSize != nil {
+		mongoOpts.SetBatchSize(*watchOpts.BatchSize)
+	}
+	if watchOpts.MaxAwaitTime != nil {
+		mongoOpts.SetMaxAwaitTime(*watchOpts.MaxAwaitTime)
+	}
 
-	// Build MongoDB update options
-	mongoOpts := options.UpdateOne()
-	if updateOpts.Upsert != nil {
-		mongoOpts.SetUpsert(*updateOpts.Upsert)
+	if pipeline == nil {
+		pipeline = mongo.Pipeline{}
 	}
 
-	result, err := collection.UpdateOne(m.ctx, filter, update, mongoOpts)
+	// Long-lived stream: cancelled by the base context, not by the operation timeout
+	ctx := m.ctx
+
+	var stream *mongo.ChangeStream
+	err := m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		stream, opErr = m.db().Watch(ctx, pipeline, mongoOpts)
+		return opErr
+	})
 	if err != nil {
 		return err
 	}
-	if !result.Acknowledged {
-		return errors.New("update not acknowledged")
-	}
+	defer stream.Close(context.Background())
 
 	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("UpdateOne")
+		m.logger().UTC().LogDebugLevelWithCaller("WatchDatabase", "pipeline", m.debugJSON(pipeline))
+	}
+
+	for stream.Next(ctx) {
+		var event bson.M
+		if err := stream.Decode(&event); err != nil {
+			return err
+		}
+		if ns, ok := event["ns"].(bson.M); ok {
+			event["collection"] = ns["coll"]
+		} else if ns, ok := event["ns"].(bson.D); ok {
+			for _, elem := range ns {
+				if elem.Key == "coll" {
+					event["collection"] = elem.Value
+				}
+			}
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
 	}
-
-	return nil
-}
-
-// UpdateManySet(collName string, filter any, update any, opts ...ref.UpdateOption) error
-// e.g db.collectionName.updateMany({_id: "123"}, {$set: {name: "John"}})
-func (m *MongoLib) UpdateManySet(collName string, filter any, update any, opts ...ref.UpdateOption) error {
-	return m.updateMany(collName, filter, ref.UpdateSet(update), opts...)
-}
-
-// UpdateManySetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error
-// e.g db.collectionName.updateMany({_id: "123"}, [{$set: {name: "$otherfield"}}])
-func (m *MongoLib) UpdateManySetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error {
-	return m.updateMany(collName, filter, ref.UpdateSetPipeline(update), opts...)
+	return stream.Err()
 }
 
-// UpdateMany updates multiple documents in the specified collection
-func (m *MongoLib) updateMany(collName string, filter any, update any, opts ...ref.UpdateOption) error {
+// InsertOne inserts a single document into the specified collection
+func (m *MongoLib) InsertOne(collName string, document any, opts ...ref.WriteOption) (any, error) {
 	if err := m.ensureConnection(); err != nil {
+		return bson.NilObjectID, err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return bson.NilObjectID, err
+	}
+
+	writeOpts := parseWriteOptions(opts)
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	mongoOpts := options.InsertOne()
+	if writeOpts.BypassDocumentValidation {
+		mongoOpts.SetBypassDocumentValidation(true)
+	}
+
+	// Prepared once so a retry resends the same generated _id
+	document, err := m.encryptFields(m.assignID(m.stampInsert(document)))
+	if err != nil {
+		return bson.NilObjectID, err
+	}
+
+	var result *mongo.InsertOneResult
+	err = m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		result, opErr = m.writeCollection(collName, writeOpts.Unacknowledged).InsertOne(ctx, document, mongoOpts)
+		return opErr
+	})
+	if err != nil {
+		return bson.NilObjectID, err
+	}
+	if !writeOpts.Unacknowledged && !result.Acknowledged {
+		return bson.NilObjectID, errors.New("insert not acknowledged")
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("InsertOne")
+	}
+
+	return result.InsertedID, nil
+}
+
+// InsertMany inserts multiple documents into the specified collection
+func (m *MongoLib) InsertMany(collName string, documents []any, opts ...ref.WriteOption) ([]any, error) {
+	if err := m.ensureConnection(); err != nil {
+		return nil, err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return nil, err
+	}
+
+	writeOpts := parseWriteOptions(opts)
+
+	stamped := make([]any, len(documents))
+	for i, document := range documents {
+		prepared, err := m.encryptFields(m.assignID(m.stampInsert(document)))
+		if err != nil {
+			return nil, err
+		}
+		stamped[i] = prepared
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	mongoOpts := options.InsertMany()
+	if writeOpts.BypassDocumentValidation {
+		mongoOpts.SetBypassDocumentValidation(true)
+	}
+
+	var result *mongo.InsertManyResult
+	err := m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		result, opErr = m.writeCollection(collName, writeOpts.Unacknowledged).InsertMany(ctx, stamped, mongoOpts)
+		return opErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !writeOpts.Unacknowledged && !result.Acknowledged {
+		return nil, errors.New("insert not acknowledged")
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("InsertMany")
+	}
+
+	return result.InsertedIDs, nil
+}
+
+// upsertModels builds one upsert per document of BulkUpsert, matched on keyField
+func (m *MongoLib) upsertModels(keyField string, documents []bson.M) ([]mongo.WriteModel, error) {
+	// A random nonce makes every ciphertext unique, an encrypted key would never match
+	if m.encryptor != nil && m.encryptor.encrypts(keyField) {
+		return nil, fmt.Errorf("field %q: an encrypted field can't be the BulkUpsert key", keyField)
+	}
+
+	models := make([]mongo.WriteModel, 0, len(documents))
+	for i, document := range documents {
+		key, ok := document[keyField]
+		if !ok {
+			return nil, fmt.Errorf("document %d has no %q field", i, keyField)
+		}
+		update, err := m.encryptUpdate(m.stampUpdate(ref.UpdateSet(document)))
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{keyField: key}).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+	return models, nil
+}
+
+// BulkUpsert upserts every document matched on its keyField in a single unordered bulk write
+// e.g for each doc: db.collectionName.updateOne({sku: doc.sku}, {$set: doc}, {upsert: true})
+func (m *MongoLib) BulkUpsert(collName string, keyField string, documents []bson.M) (*mongo.BulkWriteResult, error) {
+	if len(documents) == 0 {
+		return &mongo.BulkWriteResult{}, nil
+	}
+
+	models, err := m.upsertModels(keyField, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.ensureConnection(); err != nil {
+		return nil, err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	var result *mongo.BulkWriteResult
+	err = m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		result, opErr = m.GetCollection(collName).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		return opErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("BulkUpsert",
+			"key", keyField,
+			"documents", len(documents),
+			"matched", result.MatchedCount,
+			"upserted", result.UpsertedCount,
+		)
+	}
+
+	return result, nil
+}
+
+// DeleteOne deletes a single document from the specified collection
+func (m *MongoLib) DeleteOne(collName string, filter any, opts ...ref.WriteOption) error {
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	writeOpts := parseWriteOptions(opts)
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	var result *mongo.DeleteResult
+	err := m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		result, opErr = m.writeCollection(collName, writeOpts.Unacknowledged).DeleteOne(ctx, filter)
+		return opErr
+	})
+	if err != nil {
+		return err
+	}
+	if !writeOpts.Unacknowledged && !result.Acknowledged {
+		return errors.New("delete not acknowledged")
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("DeleteOne",
+			"filter", m.debugJSON(filter),
+			"deleted", result.DeletedCount,
+		)
+	}
+
+	return nil
+}
+
+// DeleteMany deletes multiple documents from the specified collection
+func (m *MongoLib) DeleteMany(collName string, filter any, opts ...ref.WriteOption) error {
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	writeOpts := parseWriteOptions(opts)
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	var result *mongo.DeleteResult
+	err := m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		result, opErr = m.writeCollection(collName, writeOpts.Unacknowledged).DeleteMany(ctx, filter)
+		return opErr
+	})
+	if err != nil {
+		return err
+	}
+	if !writeOpts.Unacknowledged && !result.Acknowledged {
+		return errors.New("delete not acknowledged")
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("DeleteMany",
+			"filter", m.debugJSON(filter),
+			"deleted", result.DeletedCount,
+		)
+	}
+
+	return nil
+}
+
+// DeleteManyBatched deletes the documents matching filter batchSize at a time until none remain
+// each batch fetches matching _ids then deletes them, so a huge cleanup never holds locks for long;
+// every batch gets its own operation timeout, returns the total deleted even when a batch fails
+func (m *MongoLib) DeleteManyBatched(collName string, filter any, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("batch size must be positive")
+	}
+	if err := m.ensureConnection(); err != nil {
+		return 0, err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for {
+		deleted, err := m.deleteBatch(collName, filter, batchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted == 0 {
+			break
+		}
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("DeleteManyBatched",
+			"filter", m.debugJSON(filter),
+			"batch_size", batchSize,
+			"deleted", total,
+		)
+	}
+
+	return total, nil
+}
+
+// deleteBatch deletes up to batchSize documents matching filter
+func (m *MongoLib) deleteBatch(collName string, filter any, batchSize int) (int64, error) {
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	var docs []bson.M
+	err := m.retryOnDisconnect(ctx, func() error {
+		cursor, opErr := m.GetCollection(collName).Find(ctx, filter, options.Find().
+			SetProjection(bson.M{"_id": 1}).
+			SetLimit(int64(batchSize)))
+		if opErr != nil {
+			return opErr
+		}
+		return cursor.All(ctx, &docs)
+	})
+	if err != nil || len(docs) == 0 {
+		return 0, err
+	}
+
+	ids := make(bson.A, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc["_id"]
+	}
+
+	// Keep the filter so documents changed since the find are left alone
+	batchFilter := bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$in": ids}}}}
+
+	var result *mongo.DeleteResult
+	err = m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		result, opErr = m.GetCollection(collName).DeleteMany(ctx, batchFilter)
+		return opErr
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// UpdateOneSet(collName string, filter any, update any, opts ...ref.UpdateOption) error
+// e.g db.collectionName.update({_id: "123"}, {$set: {name: "John"}})
+func (m *MongoLib) UpdateOneSet(collName string, filter any, update any, opts ...ref.UpdateOption) error {
+	return m.updateOne(collName, filter, ref.UpdateSet(update), opts...)
+}
+
+// UpdateOneSetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error
+// e.g db.collectionName.update({_id: "123"}, [{$set: {name: "$otherfield"}}])
+func (m *MongoLib) UpdateOneSetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error {
+	return m.updateOne(collName, filter, ref.UpdateSetPipeline(update), opts...)
+}
+
+// prepareUpdate adds the insert-only fields, the updated timestamp and encrypts the configured fields
+func (m *MongoLib) prepareUpdate(update any, updateOpts *ref.UpdateOptions) (any, error) {
+	// Insert-only fields go next to the update operators
+	if updateOpts.SetOnInsert != nil {
+		if _, isPipeline := update.([]bson.M); isPipeline {
+			return nil, errors.New("set on insert is not supported by pipeline updates")
+		}
+		update = ref.UpdateCombine(update, ref.UpdateSetOnInsert(updateOpts.SetOnInsert))
+	}
+	return m.encryptUpdate(m.stampUpdate(update))
+}
+
+// UpdateOne updates a single document in the specified collection
+func (m *MongoLib) updateOne(collName string, filter any, update any, opts ...ref.UpdateOption) error {
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	// Parse update options
+	updateOpts := &ref.UpdateOptions{
+		Upsert:         nil,
+		Unacknowledged: false,
+		SetOnInsert:    nil,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(updateOpts)
+	}
+
+	update, err := m.prepareUpdate(update, updateOpts)
+	if err != nil {
+		return err
+	}
+
+	// Build MongoDB update options
+	mongoOpts := options.UpdateOne()
+	if updateOpts.Upsert != nil {
+		mongoOpts.SetUpsert(*updateOpts.Upsert)
+	}
+	if updateOpts.CorrelationID != "" {
+		mongoOpts.SetComment(updateOpts.CorrelationID)
+	}
+	if updateOpts.BypassDocumentValidation {
+		mongoOpts.SetBypassDocumentValidation(true)
+	}
+
+	var result *mongo.UpdateResult
+	err = m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		result, opErr = m.writeCollection(collName, updateOpts.Unacknowledged).UpdateOne(ctx, filter, update, mongoOpts)
+		return opErr
+	})
+	if err != nil {
+		return err
+	}
+	if !updateOpts.Unacknowledged && !result.Acknowledged {
+		return errors.New("update not acknowledged")
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("UpdateOne",
+			"filter", m.debugJSON(filter),
+			"update", m.debugJSON(update),
+			"upsert", m.debugJSON(updateOpts.Upsert),
+			"correlation_id", updateOpts.CorrelationID,
+			"matched", result.MatchedCount,
+			"modified", result.ModifiedCount,
+		)
+	}
+
+	return nil
+}
+
+// versionedUpdate builds the filter and update of UpdateIfVersion
+func (m *MongoLib) versionedUpdate(filter any, expectedVersion int64, update any) (bson.M, any, error) {
+	// $and keeps the caller's filter intact whatever its type
+	versioned := bson.M{"$and": bson.A{filter, bson.M{"version": expectedVersion}}}
+	update = ref.UpdateCombine(ref.UpdateSet(update), bson.M{"$inc": bson.M{"version": 1}})
+	update, err := m.encryptUpdate(m.stampUpdate(update))
+	if err != nil {
+		return nil, nil, err
+	}
+	return versioned, update, nil
+}
+
+// UpdateIfVersion sets update on the document matching filter only while its version is still expectedVersion
+// and increments the version (optimistic locking); false means a concurrent write got there first
+// e.g db.collectionName.updateOne({_id: "123", version: 4}, {$set: {name: "John"}, $inc: {version: 1}})
+func (m *MongoLib) UpdateIfVersion(collName string, filter any, expectedVersion int64, update any) (bool, error) {
+	if err := m.ensureConnection(); err != nil {
+		return false, err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	versioned, update, err := m.versionedUpdate(filter, expectedVersion, update)
+	if err != nil {
+		return false, err
+	}
+
+	var result *mongo.UpdateResult
+	err = m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		result, opErr = m.GetCollection(collName).UpdateOne(ctx, versioned, update)
+		return opErr
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("UpdateIfVersion",
+			"filter", m.debugJSON(versioned),
+			"update", m.debugJSON(update),
+			"matched", result.MatchedCount,
+		)
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+// UpdateManySet(collName string, filter any, update any, opts ...ref.UpdateOption) error
+// e.g db.collectionName.updateMany({_id: "123"}, {$set: {name: "John"}})
+func (m *MongoLib) UpdateManySet(collName string, filter any, update any, opts ...ref.UpdateOption) error {
+	return m.updateMany(collName, filter, ref.UpdateSet(update), opts...)
+}
+
+// UpdateManySetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error
+// e.g db.collectionName.updateMany({_id: "123"}, [{$set: {name: "$otherfield"}}])
+func (m *MongoLib) UpdateManySetPipeline(collName string, filter any, update any, opts ...ref.UpdateOption) error {
+	return m.updateMany(collName, filter, ref.UpdateSetPipeline(update), opts...)
+}
+
+// UpdateMany updates multiple documents in the specified collection
+func (m *MongoLib) updateMany(collName string, filter any, update any, opts ...ref.UpdateOption) error {
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	// Parse update options
+	updateOpts := &ref.UpdateOptions{
+		Upsert:         nil,
+		Unacknowledged: false,
+		SetOnInsert:    nil,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(updateOpts)
+	}
+
+	update, err := m.prepareUpdate(update, updateOpts)
+	if err != nil {
+		return err
+	}
+
+	// Build MongoDB update options
+	mongoOpts := options.UpdateMany()
+	if updateOpts.Upsert != nil {
+		mongoOpts.SetUpsert(*updateOpts.Upsert)
+	}
+	if updateOpts.CorrelationID != "" {
+		mongoOpts.SetComment(updateOpts.CorrelationID)
+	}
+	if updateOpts.BypassDocumentValidation {
+		mongoOpts.SetBypassDocumentValidation(true)
+	}
+
+	var result *mongo.UpdateResult
+	err = m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		result, opErr = m.writeCollection(collName, updateOpts.Unacknowledged).UpdateMany(ctx, filter, update, mongoOpts)
+		return opErr
+	})
+	if err != nil {
+		return err
+	}
+	if !updateOpts.Unacknowledged && !result.Acknowledged {
+		return errors.New("update not acknowledged")
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("UpdateMany",
+			"filter", m.debugJSON(filter),
+			"update", m.debugJSON(update),
+			"upsert", m.debugJSON(updateOpts.Upsert),
+			"correlation_id", updateOpts.CorrelationID,
+			"matched", result.MatchedCount,
+			"modified", result.ModifiedCount,
+		)
+	}
+
+	return nil
+}
+
+// Aggregate aggregates documents from the specified collection
+func (m *MongoLib) Aggregate(output, pipeline any, collName string, opts ...ref.AggregateOption) error {
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	// Parse aggregate options
+	aggOpts := &ref.AggregateOptions{
+		ReadConcern: nil,
+		ReadPref:    nil,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(aggOpts)
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	var cursor *mongo.Cursor
+	err := m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		cursor, opErr = m.collection(collName, aggOpts.ReadConcern, aggOpts.ReadPref).Aggregate(ctx, pipeline)
+		return opErr
+	})
+	if err != nil {
+		return err
+	}
+
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, output); err != nil {
+		return wrapTimeout(err)
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("Aggregate",
+			"pipeline", m.debugJSON(pipeline),
+			"count", resultLen(output),
+		)
+	}
+
+	return nil
+}
+
+// AggregateOne decodes the first document of the pipeline result into output
+// e.g a $group total; returns ErrNotFound when the pipeline yields nothing
+func (m *MongoLib) AggregateOne(output, pipeline any, collName string, opts ...ref.AggregateOption) error {
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	// Parse aggregate options
+	aggOpts := &ref.AggregateOptions{
+		ReadConcern: nil,
+		ReadPref:    nil,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(aggOpts)
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	var cursor *mongo.Cursor
+	err := m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		cursor, opErr = m.collection(collName, aggOpts.ReadConcern, aggOpts.ReadPref).Aggregate(ctx, pipeline)
+		return opErr
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return wrapTimeout(err)
+		}
+		return ErrNotFound
+	}
+	if err := cursor.Decode(output); err != nil {
+		return wrapTimeout(err)
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("AggregateOne", "pipeline", m.debugJSON(pipeline))
+	}
+
+	return nil
+}
+
+// AggregateToJSON streams the aggregation results to w as a JSON array, one document at a time,
+// ObjectIDs as hex and dates as RFC3339 (see common.ToJSONMongo), so large exports stay memory-bounded
+// on error the array may be left incomplete: w has already received the documents before it
+// like Tail it ignores the default operation timeout so long exports are not cut off, bound it with WithContext
+func (m *MongoLib) AggregateToJSON(w io.Writer, pipeline any, collName string, opts ...ref.AggregateOption) error {
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	// Parse aggregate options
+	aggOpts := &ref.AggregateOptions{
+		ReadConcern: nil,
+		ReadPref:    nil,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(aggOpts)
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+
+	var cursor *mongo.Cursor
+	err := m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		cursor, opErr = m.collection(collName, aggOpts.ReadConcern, aggOpts.ReadPref).Aggregate(ctx, pipeline)
+		return opErr
+	})
+	if err != nil {
+		return err
+	}
+
+	defer cursor.Close(ctx)
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	count := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		data, err := common.ToJSONMongo(doc)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			data = "," + data
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return wrapTimeout(err)
+	}
+	if _, err := io.WriteString(w, "]"); err != nil {
+		return err
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("AggregateToJSON",
+			"pipeline", m.debugJSON(pipeline),
+			"count", count,
+		)
+	}
+
+	return nil
+}
+
+// AggregateInto runs the pipeline on srcColl and writes its output to destColl
+// mode ref.AggregateOut appends {$out: destColl}, which REPLACES the destination collection entirely
+// mode ref.AggregateMerge appends {$merge: {into: destColl}}, which upserts documents by _id
+func (m *MongoLib) AggregateInto(pipeline any, srcColl, destColl string, mode string, opts ...ref.AggregateOption) error {
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(srcColl, destColl); err != nil {
+		return err
+	}
+
+	// Parse aggregate options
+	aggOpts := &ref.AggregateOptions{
+		BypassDocumentValidation: false,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(aggOpts)
+	}
+
+	var stage bson.D
+	switch mode {
+	case ref.AggregateOut:
+		stage = bson.D{{Key: "$out", Value: destColl}}
+	case ref.AggregateMerge:
+		stage = bson.D{{Key: "$merge", Value: bson.D{
+			{Key: "into", Value: destColl},
+			{Key: "on", Value: "_id"},
+			{Key: "whenMatched", Value: "merge"},
+			{Key: "whenNotMatched", Value: "insert"},
+		}}}
+	default:
+		return fmt.Errorf("unsupported aggregate write mode %q", mode)
+	}
+
+	stages, err := ref.AppendStage(pipeline, stage)
+	if err != nil {
 		return err
 	}
 
-	// Parse update options
-	updateOpts := &ref.UpdateOptions{
-		Upsert: nil,
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	mongoOpts := options.Aggregate()
+	if aggOpts.BypassDocumentValidation {
+		mongoOpts.SetBypassDocumentValidation(true)
+	}
+
+	err = m.retryOnDisconnect(ctx, func() error {
+		cursor, opErr := m.GetCollection(srcColl).Aggregate(ctx, stages, mongoOpts)
+		if opErr != nil {
+			return opErr
+		}
+		defer cursor.Close(ctx)
+		return cursor.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("AggregateInto", "pipeline", m.debugJSON(stages))
+	}
+
+	return nil
+}
+
+// NextSequence atomically increments and returns the counter name stored in collName
+// e.g db.counters.findOneAndUpdate({_id: "orders"}, {$inc: {seq: 1}}, {upsert: true, returnDocument: "after"})
+func (m *MongoLib) NextSequence(collName, name string) (int64, error) {
+	if err := m.ensureConnection(); err != nil {
+		return 0, err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	mongoOpts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := m.retryOnDisconnect(ctx, func() error {
+		return m.GetCollection(collName).FindOneAndUpdate(ctx,
+			bson.M{"_id": name},
+			bson.M{"$inc": bson.M{"seq": 1}},
+			mongoOpts,
+		).Decode(&counter)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("NextSequence", "name", name, "seq", counter.Seq)
+	}
+
+	return counter.Seq, nil
+}
+
+// Count counts the number of documents in the specified collection
+// e.g db.coll.countDocuments({status: "active"}, {limit: 1000, hint: "status_1"})
+func (m *MongoLib) Count(collName string, filter any, opts ...ref.CountOption) (int64, error) {
+	if err := m.ensureConnection(); err != nil {
+		return 0, err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	// Parse count options
+	countOpts := &ref.CountOptions{
+		Limit:   nil,
+		Skip:    nil,
+		Hint:    nil,
+		MaxTime: 0,
 	}
 
 	// Apply options
 	for _, opt := range opts {
-		opt(updateOpts)
+		opt(countOpts)
 	}
 
-	collection := m.GetCollection(collName)
+	// Build MongoDB count options
+	mongoOpts := options.Count()
+	if countOpts.Limit != nil {
+		mongoOpts.SetLimit(*countOpts.Limit)
+	}
+	if countOpts.Skip != nil {
+		mongoOpts.SetSkip(*countOpts.Skip)
+	}
+	if countOpts.Hint != nil {
+		mongoOpts.SetHint(countOpts.Hint)
+	}
 
-	// Build MongoDB update options
-	mongoOpts := options.UpdateMany()
-	if updateOpts.Upsert != nil {
-		mongoOpts.SetUpsert(*updateOpts.Upsert)
+	// The v2 driver dropped maxTimeMS, a context deadline bounds the count instead
+	if countOpts.MaxTime > 0 {
+		var cancelMaxTime context.CancelFunc
+		ctx, cancelMaxTime = context.WithTimeout(ctx, countOpts.MaxTime)
+		defer cancelMaxTime()
 	}
 
-	result, err := collection.UpdateMany(m.ctx, filter, update, mongoOpts)
+	var count int64
+	err := m.retryOnDisconnect(ctx, func() error {
+		var opErr error
+		count, opErr = m.GetCollection(collName).CountDocuments(ctx, filter, mongoOpts)
+		return opErr
+	})
 	if err != nil {
+		return 0, err
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("CountDocuments", "filter", m.debugJSON(filter))
+	}
+
+	return count, nil
+}
+
+// CreateCollection explicitly creates a collection with the given options
+// e.g db.createCollection("logs", {capped: true, size: 1048576, max: 1000})
+func (m *MongoLib) CreateCollection(name string, opts ...ref.CollectionOption) error {
+	if err := m.ensureConnection(); err != nil {
 		return err
 	}
-	if !result.Acknowledged {
-		return errors.New("update not acknowledged")
+	if err := m.checkCollections(name); err != nil {
+		return err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	// Parse collection options
+	collOpts := &ref.CollectionOptions{
+		Capped:       nil,
+		SizeInBytes:  nil,
+		MaxDocuments: nil,
+		Validator:    nil,
+		TimeSeries:   nil,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(collOpts)
+	}
+
+	// Build MongoDB create collection options
+	mongoOpts := options.CreateCollection()
+	if collOpts.Capped != nil {
+		mongoOpts.SetCapped(*collOpts.Capped)
+	}
+	if collOpts.SizeInBytes != nil {
+		mongoOpts.SetSizeInBytes(*collOpts.SizeInBytes)
+	}
+	if collOpts.MaxDocuments != nil {
+		mongoOpts.SetMaxDocuments(*collOpts.MaxDocuments)
+	}
+	if collOpts.Validator != nil {
+		mongoOpts.SetValidator(collOpts.Validator)
+	}
+	if ts := collOpts.TimeSeries; ts != nil {
+		if ts.TimeField == "" {
+			return errors.New("time-series collection requires a time field")
+		}
+		tsOpts := options.TimeSeries().SetTimeField(ts.TimeField)
+		if ts.MetaField != "" {
+			tsOpts.SetMetaField(ts.MetaField)
+		}
+		if ts.Granularity != "" {
+			tsOpts.SetGranularity(ts.Granularity)
+		}
+		mongoOpts.SetTimeSeriesOptions(tsOpts)
+	}
+
+	if err := m.db().CreateCollection(ctx, name, mongoOpts); err != nil {
+		return wrapTimeout(err)
 	}
 
 	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("UpdateMany")
+		m.logger().UTC().LogDebugLevelWithCaller("CreateCollection")
 	}
 
 	return nil
 }
 
-// Aggregate aggregates documents from the specified collection
-func (m *MongoLib) Aggregate(output, pipeline any, collName string) error {
+// CreateTimeSeriesCollection creates a time-series collection (MongoDB 5.0+), writes go through InsertOne/InsertMany as usual
+// metaField and granularity ("seconds", "minutes", "hours") are optional
+// e.g db.createCollection("metrics", {timeseries: {timeField: "ts", metaField: "tags", granularity: "minutes"}})
+func (m *MongoLib) CreateTimeSeriesCollection(name, timeField, metaField string, granularity string) error {
+	return m.CreateCollection(name, ref.WithTimeSeries(timeField, metaField, granularity))
+}
+
+// DropCollection drops the specified collection and all of its indexes
+func (m *MongoLib) DropCollection(name string) error {
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(name); err != nil {
+		return err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	if err := m.GetCollection(name).Drop(ctx); err != nil {
+		return wrapTimeout(err)
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("DropCollection")
+	}
+
+	return nil
+}
+
+// ListCollections returns the names of every collection in the database
+func (m *MongoLib) ListCollections() ([]string, error) {
+	if err := m.ensureConnection(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	names, err := m.db().ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("ListCollections", "count", len(names))
+	}
+
+	return names, nil
+}
+
+// CollectionExists reports whether the collection exists in the database
+func (m *MongoLib) CollectionExists(name string) (bool, error) {
+	if err := m.ensureConnection(); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	names, err := m.db().ListCollectionNames(ctx, bson.M{"name": name})
+	if err != nil {
+		return false, wrapTimeout(err)
+	}
+
+	return len(names) > 0, nil
+}
+
+// CollectionStats returns document count and storage sizes of the collection
+// e.g db.runCommand({collStats: "collectionName"})
+func (m *MongoLib) CollectionStats(collName string) (*ref.CollStats, error) {
+	if err := m.ensureConnection(); err != nil {
+		return nil, err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	stats := &ref.CollStats{}
+	cmd := bson.D{{Key: "collStats", Value: collName}}
+	if err := m.db().RunCommand(ctx, cmd).Decode(stats); err != nil {
+		return nil, wrapTimeout(err)
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("CollectionStats", "collection", collName)
+	}
+
+	return stats, nil
+}
+
+// DropDatabase drops the whole database
+// confirmName must match the configured database name, otherwise nothing is dropped
+func (m *MongoLib) DropDatabase(confirmName string) error {
 	if err := m.ensureConnection(); err != nil {
 		return err
 	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	if confirmName == "" || confirmName != m.GetDatabaseName() {
+		return errors.New("drop database not confirmed: name does not match")
+	}
+
+	if err := m.db().Drop(ctx); err != nil {
+		return wrapTimeout(err)
+	}
+
+	m.logger().UTC().LogWarnLevel("msg", "MongoDB database dropped:", confirmName)
+
+	return nil
+}
+
+// CreateIndex creates an index on the specified collection and returns its name
+// e.g db.collectionName.createIndex({title: "text", body: "text"}) using ref.TextIndex("title", "body")
+func (m *MongoLib) CreateIndex(collName string, keys any, opts ...ref.IndexOption) (string, error) {
+	// Parse index options
+	indexOpts := &ref.IndexOptions{
+		Name:                    nil,
+		Unique:                  nil,
+		Weights:                 nil,
+		DefaultLanguage:         nil,
+		ExpireAfter:             nil,
+		PartialFilterExpression: nil,
+		Sparse:                  nil,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(indexOpts)
+	}
+
+	var expireAfter *int32
+	if indexOpts.ExpireAfter != nil {
+		seconds, err := ttlSeconds(*indexOpts.ExpireAfter)
+		if err != nil {
+			return "", err
+		}
+		expireAfter = &seconds
+	}
+
+	if err := m.ensureConnection(); err != nil {
+		return "", err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	// Build MongoDB index options
+	mongoOpts := options.Index()
+	if indexOpts.Name != nil {
+		mongoOpts.SetName(*indexOpts.Name)
+	}
+	if indexOpts.Unique != nil {
+		mongoOpts.SetUnique(*indexOpts.Unique)
+	}
+	if indexOpts.Weights != nil {
+		mongoOpts.SetWeights(indexOpts.Weights)
+	}
+	if indexOpts.DefaultLanguage != nil {
+		mongoOpts.SetDefaultLanguage(*indexOpts.DefaultLanguage)
+	}
+	if expireAfter != nil {
+		mongoOpts.SetExpireAfterSeconds(*expireAfter)
+	}
+	if indexOpts.PartialFilterExpression != nil {
+		mongoOpts.SetPartialFilterExpression(indexOpts.PartialFilterExpression)
+	}
+	if indexOpts.Sparse != nil {
+		mongoOpts.SetSparse(*indexOpts.Sparse)
+	}
+
 	collection := m.GetCollection(collName)
-	cursor, err := collection.Aggregate(m.ctx, pipeline)
+	name, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: mongoOpts,
+	})
+	if err != nil {
+		return "", wrapTimeout(err)
+	}
+
+	if m.isdebug {
+		m.logger().UTC().LogDebugLevelWithCaller("CreateIndex", "keys", m.debugJSON(keys))
+	}
+
+	return name, nil
+}
+
+// ErrInvalidTTL is returned by EnsureTTLIndex and CreateIndex (ref.WithExpireAfter)
+// when ttl is under 1s or over math.MaxInt32 seconds
+var ErrInvalidTTL = errors.New("ttl must be between 1s and math.MaxInt32 seconds")
+
+// ttlSeconds converts ttl to the whole seconds of expireAfterSeconds, checking the range first
+func ttlSeconds(ttl time.Duration) (int32, error) {
+	seconds := int64(ttl / time.Second)
+	if seconds < 1 || seconds > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: got %s", ErrInvalidTTL, ttl)
+	}
+	return int32(seconds), nil
+}
+
+// EnsureTTLIndex makes sure field has a TTL index expiring documents after ttl, truncated to whole seconds
+// it is idempotent: an existing index with the same expiry is kept, a different expiry is updated in place
+func (m *MongoLib) EnsureTTLIndex(collName, field string, ttl time.Duration) error {
+	seconds, err := ttlSeconds(ttl)
 	if err != nil {
 		return err
 	}
 
+	if err := m.ensureConnection(); err != nil {
+		return err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
+	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
+	keys := bson.D{{Key: field, Value: 1}}
+
+	collection := m.GetCollection(collName)
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
+	var indexes []struct {
+		Key                bson.D `bson:"key"`
+		ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return wrapTimeout(err)
+	}
+
+	for _, index := range indexes {
+		if len(index.Key) != 1 || index.Key[0].Key != field {
+			continue
+		}
+		if index.ExpireAfterSeconds != nil && *index.ExpireAfterSeconds == int64(seconds) {
+			return nil
+		}
+
+		// Same key with another expiry, update it in place
+		cmd := bson.D{
+			{Key: "collMod", Value: collName},
+			{Key: "index", Value: bson.D{
+				{Key: "keyPattern", Value: index.Key},
+				{Key: "expireAfterSeconds", Value: seconds},
+			}},
+		}
+		if err := m.db().RunCommand(ctx, cmd).Err(); err != nil {
+			return wrapTimeout(err)
+		}
+
+		if m.isdebug {
+			m.logger().UTC().LogDebugLevelWithCaller("EnsureTTLIndex", "field", field, "expireAfterSeconds", seconds)
+		}
+		return nil
+	}
+
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetExpireAfterSeconds(seconds),
+	})
+	if err != nil {
+		return wrapTimeout(err)
+	}
+
 	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("Aggregate")
+		m.logger().UTC().LogDebugLevelWithCaller("EnsureTTLIndex", "field", field, "expireAfterSeconds", seconds)
 	}
 
-	return cursor.All(m.ctx, output)
+	return nil
 }
 
-// Count counts the number of documents in the specified collection
-func (m *MongoLib) Count(collName string, filter any) (int64, error) {
+// EnsureUniqueIndex makes sure a unique index exists on fields, meant to be called at startup
+// it is a no-op when a unique index with the same keys exists, and fails when the same keys
+// are indexed without the unique constraint since that index would have to be dropped first
+func (m *MongoLib) EnsureUniqueIndex(collName string, fields bson.D) error {
 	if err := m.ensureConnection(); err != nil {
-		return 0, err
+		return err
+	}
+	if err := m.checkCollections(collName); err != nil {
+		return err
 	}
+
+	ctx, cancel := m.operationContext()
+	defer cancel()
+
 	collection := m.GetCollection(collName)
-	count, err := collection.CountDocuments(m.ctx, filter)
+	cursor, err := collection.Indexes().List(ctx)
 	if err != nil {
-		return 0, err
+		return wrapTimeout(err)
+	}
+
+	var indexes []struct {
+		Name   string `bson:"name"`
+		Key    bson.D `bson:"key"`
+		Unique bool   `bson:"unique"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return wrapTimeout(err)
+	}
+
+	for _, index := range indexes {
+		if !sameIndexKeys(index.Key, fields) {
+			continue
+		}
+		if index.Unique {
+			return nil
+		}
+		return fmt.Errorf("index %s already exists on %s without the unique constraint", index.Name, collName)
+	}
+
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    fields,
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return wrapTimeout(err)
 	}
 
 	if m.isdebug {
-		m.logger().UTC().LogDebugLevelWithCaller("CountDocuments")
+		m.logger().UTC().LogDebugLevelWithCaller("EnsureUniqueIndex", "keys", m.debugJSON(fields))
 	}
 
-	return count, nil
+	return nil
+}
+
+// sameIndexKeys compares index key patterns, field order and direction included
+func sameIndexKeys(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || common.ParseString(a[i].Value) != common.ParseString(b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// operationContext derives the context of a single operation
+// the operation timeout only applies when the base context has no deadline
+func (m *MongoLib) operationContext() (context.Context, context.CancelFunc) {
+	if _, ok := m.ctx.Deadline(); ok || m.opTimeout <= 0 {
+		return context.WithCancel(m.ctx)
+	}
+	return context.WithTimeout(m.ctx, m.opTimeout)
+}
+
+// isDisconnectError reports whether err means the client or topology is gone
+// (closed client, closed topology or no server selectable) rather than a failed operation
+func isDisconnectError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, mongo.ErrClientDisconnected) || errors.Is(err, topology.ErrTopologyClosed) {
+		return true
+	}
+	// Server selection also fails when the caller cancels, the client is fine then
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var selectionErr topology.ServerSelectionError
+	return errors.As(err, &selectionErr)
+}
+
+// retryOnDisconnect runs op under ctx and, when it fails on a disconnected client, reconnects and retries once
+// a done ctx is never retried: the client shared by every copy must not be replaced for one caller's deadline;
+// errors of operations running past their deadline are returned as *common.TimeoutError
+func (m *MongoLib) retryOnDisconnect(ctx context.Context, op func() error) error {
+	stale := m.db()
+	err := op()
+	if ctx.Err() != nil || !isDisconnectError(err) {
+		return wrapTimeout(err)
+	}
+
+	m.logger().UTC().LogWarnLevel("msg", "Client disconnected, reconnecting and retrying:", err.Error())
+	if connErr := m.reconnect(stale); connErr != nil {
+		return wrapTimeout(err)
+	}
+	return wrapTimeout(op())
+}
+
+// wrapTimeout turns deadline errors (context or driver side) into *common.TimeoutError
+func wrapTimeout(err error) error {
+	if err == nil || !mongo.IsTimeout(err) {
+		return err
+	}
+	return &common.TimeoutError{Err: err}
 }
 
 // ensureConnection checks if connection is alive and reconnects if needed
 func (m *MongoLib) ensureConnection() error {
-	if m.client == nil {
-		return m.connect()
+	database := m.db()
+	if database == nil {
+		return m.reconnect(nil)
 	}
 
 	// Ping to check if connection is still alive
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	if err := m.client.Ping(ctx, readpref.Primary()); err != nil {
+	if err := database.Client().Ping(ctx, readpref.Primary()); err != nil {
 		m.logger().UTC().LogWarnLevel("msg", "Connection lost, attempting to reconnect:", err.Error())
 		// Try to reconnect
-		return m.connect()
+		return m.reconnect(database)
 	}
 
 	return nil
 }
 
+// Debug returns a shallow copy with debug logging enabled
+// the copy shares the client and pool, the receiver (e.g the shared singleton) is left untouched
+// filters, updates and pipelines are logged verbatim, personal data included: mask it with WithDebugRedaction
 func (m *MongoLib) Debug() *MongoLib {
-	m.isdebug = true
-	return m
+	clone := *m
+	clone.isdebug = true
+	return &clone
+}
+
+// WithContext returns a shallow copy running its operations under ctx
+// a deadline on ctx replaces the per operation timeout (see WithOperationTimeout)
+func (m *MongoLib) WithContext(ctx context.Context) *MongoLib {
+	clone := *m
+	clone.ctx = ctx
+	return &clone
+}
+
+// WithDeadlineBudget runs fn with a child of ctx expiring after d, so a series of operations shares one overall timeout
+// operations don't each get the full budget, a slow early call leaves less time for the ones after it
+// e.g db.WithDeadlineBudget(ctx, 5*time.Second, func(ctx context.Context) error { return m.WithContext(ctx).FindOne(&user, filter, "users") })
+func WithDeadlineBudget(ctx context.Context, d time.Duration, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	return fn(ctx)
+}
+
+// resultLen returns the number of decoded results in output (a pointer to a slice)
+func resultLen(output any) int {
+	rv := reflect.ValueOf(output)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return 0
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return 0
+	}
+	return rv.Len()
+}
+
+// debugJSON serializes filters and options for debug logging
+// documents are rendered as relaxed extended JSON, anything else falls back to plain JSON;
+// values of the WithDebugRedaction fields are masked
+func (m *MongoLib) debugJSON(v any) string {
+	if len(m.redact) > 0 {
+		v = redactValue(v, m.redact)
+	}
+	if b, err := bson.MarshalExtJSON(v, false, false); err == nil {
+		return string(b)
+	}
+	return common.ToJSON(v)
 }
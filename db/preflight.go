@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+)
+
+// Preflight runs the startup checks in order and returns the first failure
+// e.g before serving traffic: db.Preflight(m, db.PingCheck, func(m db.IMongoLib) error { return m.EnsureUniqueIndex("users", bson.D{{Key: "email", Value: 1}}) })
+func Preflight(m IMongoLib, checks ...func(IMongoLib) error) error {
+	if m == nil {
+		return errors.New("preflight: no MongoDB connection")
+	}
+
+	for i, check := range checks {
+		if err := check(m); err != nil {
+			return fmt.Errorf("preflight check %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// PingCheck is a Preflight check verifying the primary answers
+func PingCheck(m IMongoLib) error {
+	client := m.GetClient()
+	if client == nil {
+		return errors.New("client not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return client.Ping(ctx, readpref.Primary())
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// redactedValue replaces the masked values in debug logs
+const redactedValue = "***"
+
+// WithDebugRedaction masks the values of fields in debug logs (see Debug), at any depth and
+// under operators, e.g WithDebugRedaction("email", "password") logs {"email": "***"};
+// a dotted key matches on its last segment, so "profile.email" is masked too
+func WithDebugRedaction(fields ...string) MongoOption {
+	return func(m *MongoLib) {
+		m.redact = make(map[string]struct{}, len(fields))
+		for _, field := range fields {
+			m.redact[field] = struct{}{}
+		}
+	}
+}
+
+// redactValue returns v as a bson document with the fields values masked,
+// v is returned unchanged when it can't be represented as bson
+func redactValue(v any, fields map[string]struct{}) any {
+	data, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return v
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil || len(doc) != 1 {
+		return v
+	}
+	return redactNode(doc[0].Value, fields)
+}
+
+func redactNode(v any, fields map[string]struct{}) any {
+	switch val := v.(type) {
+	case bson.D:
+		out := make(bson.D, len(val))
+		for i, e := range val {
+			if redactedKey(e.Key, fields) {
+				out[i] = bson.E{Key: e.Key, Value: redactedValue}
+				continue
+			}
+			out[i] = bson.E{Key: e.Key, Value: redactNode(e.Value, fields)}
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, len(val))
+		for i, item := range val {
+			out[i] = redactNode(item, fields)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func redactedKey(key string, fields map[string]struct{}) bool {
+	if _, ok := fields[key]; ok {
+		return true
+	}
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		_, ok := fields[key[i+1:]]
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestDebugJSONRedaction(t *testing.T) {
+	m := newMongoLib(WithDebugRedaction("email", "password"))
+
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"top level", bson.D{{Key: "age", Value: 3}, {Key: "email", Value: "a@b.c"}}, `{"age":3,"email":"***"}`},
+		{"operator", bson.D{{Key: "email", Value: bson.M{"$in": bson.A{"a@b.c"}}}}, `{"email":"***"}`},
+		{"dotted", bson.M{"profile.email": "a@b.c"}, `{"profile.email":"***"}`},
+		{"update", bson.M{"$set": bson.M{"password": "x"}}, `{"$set":{"password":"***"}}`},
+		{"pipeline", []bson.M{{"$match": bson.M{"email": "a@b.c"}}}, `[{"$match":{"email":"***"}}]`},
+		{"scalar", int64(5), `5`},
+	}
+	for _, tt := range tests {
+		got := strings.ReplaceAll(m.debugJSON(tt.in), " ", "")
+		if got != tt.want {
+			t.Errorf("%s: debugJSON() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDebugJSONWithoutRedaction(t *testing.T) {
+	m := newMongoLib()
+	if got := m.debugJSON(bson.M{"email": "a@b.c"}); !strings.Contains(got, "a@b.c") {
+		t.Fatalf("debugJSON() = %s, want the value logged verbatim", got)
+	}
+}
@@ -1,6 +1,16 @@
 package ref
 
-import "go.mongodb.org/mongo-driver/v2/bson"
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ranggadablues/gosok/common"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+)
 
 type IMongoHelper interface {
 }
@@ -20,18 +30,416 @@ func UpdateUnset(update any) any {
 	return bson.M{"$unset": update}
 }
 
+// UpdateSetOnInsert builds a $setOnInsert, applied only when an upsert inserts a new document
+// e.g ref.UpdateCombine(ref.UpdateSet(changes), ref.UpdateSetOnInsert(bson.M{"created_at": time.Now()}))
+func UpdateSetOnInsert(fields any) any {
+	return bson.M{"$setOnInsert": fields}
+}
+
+// UpdateCombine merges update documents into one, fields of a repeated operator are merged
+// e.g {$set: {a: 1}} + {$set: {b: 2}, $inc: {n: 1}} becomes {$set: {a: 1, b: 2}, $inc: {n: 1}}
+func UpdateCombine(updates ...any) any {
+	combined := bson.M{}
+	for _, update := range updates {
+		for op, fields := range toDocument(update) {
+			existing, hasExisting := combined[op]
+			if !hasExisting {
+				combined[op] = fields
+				continue
+			}
+
+			merged := toDocument(existing)
+			for k, v := range toDocument(fields) {
+				merged[k] = v
+			}
+			combined[op] = merged
+		}
+	}
+	return combined
+}
+
+// toDocument returns a shallow bson.M copy of a document, structs and other values go through a bson round trip
+// values that are not documents (nil, numbers, strings...) give an empty document, never nil
+func toDocument(v any) bson.M {
+	switch val := v.(type) {
+	case bson.M:
+		return copyDocument(val)
+	case map[string]any:
+		return copyDocument(val)
+	case bson.D:
+		doc := make(bson.M, len(val))
+		for _, elem := range val {
+			doc[elem.Key] = elem.Value
+		}
+		return doc
+	}
+
+	doc := bson.M{}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return doc
+	}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return bson.M{}
+	}
+	return doc
+}
+
+func copyDocument(in map[string]any) bson.M {
+	doc := make(bson.M, len(in))
+	for k, v := range in {
+		doc[k] = v
+	}
+	return doc
+}
+
+// UpdateSetNonZero builds a $set from a struct including only its non-zero fields (PATCH semantics)
+// field names follow the bson tags, fields tagged "-" are skipped; non-struct values are set as is
+func UpdateSetNonZero(v any) any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return bson.M{"$set": bson.M{}}
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return UpdateSet(v)
+	}
+
+	return bson.M{"$set": nonZeroFields(rv)}
+}
+
+// nonZeroFields collects the non-zero exported fields of a struct keyed by their bson name
+func nonZeroFields(rv reflect.Value) bson.M {
+	fields := bson.M{}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, inline := bsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		value := rv.Field(i)
+		if inline && value.Kind() == reflect.Struct {
+			for k, v := range nonZeroFields(value) {
+				fields[k] = v
+			}
+			continue
+		}
+		if value.IsZero() {
+			continue
+		}
+		fields[name] = value.Interface()
+	}
+	return fields
+}
+
+// ProjectionFromStruct builds an inclusion projection ({field: 1}) from the bson tags of a struct
+// e.g ref.WithProjection(ref.ProjectionFromStruct(User{})) fetches only the fields User decodes
+func ProjectionFromStruct(v any) bson.D {
+	rt := reflect.TypeOf(v)
+	for rt != nil && rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return bson.D{}
+	}
+	return projectionFields(rt)
+}
+
+// ProjectSlice projects only the first n elements of an array field, the last -n when n is negative
+// e.g ref.WithProjection(bson.D{ref.ProjectSlice("comments", -5)}) gives db.collectionName.find({}, {comments: {$slice: -5}})
+func ProjectSlice(field string, n int) bson.E {
+	return bson.E{Key: field, Value: bson.M{"$slice": n}}
+}
+
+// ProjectSliceRange projects limit elements of an array field after skipping skip of them
+// e.g db.collectionName.find({}, {comments: {$slice: [20, 10]}})
+func ProjectSliceRange(field string, skip, limit int) bson.E {
+	return bson.E{Key: field, Value: bson.M{"$slice": bson.A{skip, limit}}}
+}
+
+func projectionFields(rt reflect.Type) bson.D {
+	projection := bson.D{}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, inline := bsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if inline && fieldType.Kind() == reflect.Struct {
+			projection = append(projection, projectionFields(fieldType)...)
+			continue
+		}
+		projection = append(projection, bson.E{Key: name, Value: 1})
+	}
+	return projection
+}
+
+// BuildFilter builds an equality filter from a struct of optional parameters
+// non-nil pointer fields become {field: value} keyed by their bson name, nil and non-pointer fields are omitted
+// e.g struct{ Status *string `bson:"status"`; Age *int `bson:"age"` }{Status: &active} gives {status: "active"}
+func BuildFilter(v any) bson.M {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return bson.M{}
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return bson.M{}
+	}
+	return filterFields(rv)
+}
+
+func filterFields(rv reflect.Value) bson.M {
+	filter := bson.M{}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, inline := bsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		value := rv.Field(i)
+		if inline && value.Kind() == reflect.Struct {
+			for k, v := range filterFields(value) {
+				filter[k] = v
+			}
+			continue
+		}
+		if value.Kind() != reflect.Ptr || value.IsNil() {
+			continue
+		}
+		filter[name] = value.Elem().Interface()
+	}
+	return filter
+}
+
+// bsonFieldName returns the bson key of a struct field and whether it is inlined
+func bsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("bson")
+	if tag == "" {
+		return strings.ToLower(field.Name), false
+	}
+
+	parts := strings.Split(tag, ",")
+	inline := false
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			inline = true
+		}
+	}
+	if parts[0] == "" {
+		return strings.ToLower(field.Name), inline
+	}
+	return parts[0], inline
+}
+
 func UpdateSetPipeline(update any) any {
 	return []bson.M{{"$set": update}}
 }
 
+// TextSearch builds a $text filter, the collection needs a text index (see TextIndex)
+// e.g db.collectionName.find({$text: {$search: "coffee shop"}})
+func TextSearch(query string) bson.M {
+	return bson.M{"$text": bson.M{"$search": query}}
+}
+
+// Expr wraps an aggregation expression into a $expr filter
+// e.g db.collectionName.find({$expr: {$gt: ["$spent", "$budget"]}})
+func Expr(expression bson.M) bson.M {
+	return bson.M{"$expr": expression}
+}
+
+// FieldEq matches documents where field a equals field b
+func FieldEq(a, b string) bson.M {
+	return fieldCompare("$eq", a, b)
+}
+
+// FieldNe matches documents where field a differs from field b
+func FieldNe(a, b string) bson.M {
+	return fieldCompare("$ne", a, b)
+}
+
+// FieldGt matches documents where field a is greater than field b
+func FieldGt(a, b string) bson.M {
+	return fieldCompare("$gt", a, b)
+}
+
+// FieldGte matches documents where field a is greater than or equal to field b
+func FieldGte(a, b string) bson.M {
+	return fieldCompare("$gte", a, b)
+}
+
+// FieldLt matches documents where field a is less than field b
+func FieldLt(a, b string) bson.M {
+	return fieldCompare("$lt", a, b)
+}
+
+// FieldLte matches documents where field a is less than or equal to field b
+func FieldLte(a, b string) bson.M {
+	return fieldCompare("$lte", a, b)
+}
+
+func fieldCompare(op, a, b string) bson.M {
+	return Expr(bson.M{op: bson.A{"$" + a, "$" + b}})
+}
+
+// ElemMatch matches documents where a single element of the array field satisfies every condition
+// e.g db.orders.find({items: {$elemMatch: {product: "X", qty: {$gt: 2}}}})
+func ElemMatch(field string, conditions bson.M) bson.M {
+	return bson.M{field: bson.M{"$elemMatch": conditions}}
+}
+
+// TextIndex builds the keys of a text index over the given fields
+func TextIndex(fields ...string) bson.D {
+	keys := bson.D{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: "text"})
+	}
+	return keys
+}
+
+// GeoIndex builds the keys of a 2dsphere index over field, needed by Near
+// e.g m.CreateIndex("places", ref.GeoIndex("location"))
+func GeoIndex(field string) bson.D {
+	return bson.D{{Key: field, Value: "2dsphere"}}
+}
+
+// GeoPoint builds a GeoJSON point to store in a 2dsphere indexed field, longitude comes first
+func GeoPoint(lng, lat float64) bson.M {
+	return bson.M{"type": "Point", "coordinates": bson.A{lng, lat}}
+}
+
+// Near matches documents by distance to a point, nearest first, within maxMeters (no limit when <= 0)
+// e.g db.collectionName.find({location: {$near: {$geometry: {type: "Point", coordinates: [lng, lat]}, $maxDistance: 500}}})
+func Near(field string, lng, lat, maxMeters float64) bson.M {
+	near := bson.M{"$geometry": GeoPoint(lng, lat)}
+	if maxMeters > 0 {
+		near["$maxDistance"] = maxMeters
+	}
+	return bson.M{field: bson.M{"$near": near}}
+}
+
+// Aggregation write modes for AggregateInto
+const (
+	AggregateOut   = "$out"   // replaces the destination collection entirely
+	AggregateMerge = "$merge" // upserts into the destination collection by _id
+)
+
+// AppendStage returns a copy of pipeline (any slice of stages) with stage appended
+func AppendStage(pipeline any, stage any) ([]any, error) {
+	if pipeline == nil {
+		return []any{stage}, nil
+	}
+
+	rv := reflect.ValueOf(pipeline)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, errors.New("pipeline must be a slice of stages")
+	}
+
+	stages := make([]any, 0, rv.Len()+1)
+	for i := 0; i < rv.Len(); i++ {
+		stages = append(stages, rv.Index(i).Interface())
+	}
+	return append(stages, stage), nil
+}
+
+// Group builds a $group stage, fields map output names to accumulators (see Sum, Avg, Count, Max, Min)
+// e.g sales per month: ref.Group(bson.M{"$month": "$date"}, map[string]bson.M{"total": ref.Sum("$amount"), "orders": ref.Count()})
+func Group(id any, fields map[string]bson.M) bson.M {
+	group := bson.M{"_id": id}
+	for name, accumulator := range fields {
+		group[name] = accumulator
+	}
+	return bson.M{"$group": group}
+}
+
+// Sum accumulates the total of expr, e.g ref.Sum("$amount")
+func Sum(expr any) bson.M {
+	return bson.M{"$sum": expr}
+}
+
+// Avg accumulates the average of expr
+func Avg(expr any) bson.M {
+	return bson.M{"$avg": expr}
+}
+
+// Count accumulates the number of documents in the group
+func Count() bson.M {
+	return Sum(1)
+}
+
+// Max accumulates the highest value of expr
+func Max(expr any) bson.M {
+	return bson.M{"$max": expr}
+}
+
+// Min accumulates the lowest value of expr
+func Min(expr any) bson.M {
+	return bson.M{"$min": expr}
+}
+
+// Bucket builds a $bucket stage grouping documents into the ranges set by sorted boundaries
+// defaultBucket collects values outside the boundaries and output the accumulators, both skipped when nil
+// e.g users by age: ref.Bucket("$age", []any{0, 18, 30, 50}, "other", bson.M{"count": ref.Count()})
+func Bucket(groupBy any, boundaries []any, defaultBucket any, output bson.M) bson.M {
+	bucket := bson.M{"groupBy": groupBy, "boundaries": boundaries}
+	if defaultBucket != nil {
+		bucket["default"] = defaultBucket
+	}
+	if len(output) > 0 {
+		bucket["output"] = output
+	}
+	return bson.M{"$bucket": bucket}
+}
+
+// BucketAuto builds a $bucketAuto stage spreading documents evenly over the given number of buckets
+// output is skipped when empty, e.g ref.BucketAuto("$price", 5, bson.M{"count": ref.Count()})
+func BucketAuto(groupBy any, buckets int, output bson.M) bson.M {
+	bucket := bson.M{"groupBy": groupBy, "buckets": buckets}
+	if len(output) > 0 {
+		bucket["output"] = output
+	}
+	return bson.M{"$bucketAuto": bucket}
+}
+
 // FindOption allows customizing find operations
 type FindOption func(*FindOptions)
 
 type FindOptions struct {
-	Limit      *int64
-	Skip       *int64
-	Sort       any
-	Projection any
+	Limit          *int64
+	Skip           *int64
+	Sort           any
+	Projection     any
+	Timing         func(time.Duration)
+	CorrelationID  string
+	StrictIDs      bool
+	ReadConcern    *readconcern.ReadConcern
+	ReadPref       *readpref.ReadPref
+	CommandCapture func(cmd bson.Raw)
 }
 
 // WithLimit sets the limit for find operations
@@ -62,11 +470,253 @@ func WithProjection(projection any) FindOption {
 	}
 }
 
+// WithTiming calls fn with the execution duration once the find operation completes
+func WithTiming(fn func(time.Duration)) FindOption {
+	return func(opts *FindOptions) {
+		opts.Timing = fn
+	}
+}
+
+// WithCorrelationID sets id as the query comment (visible in the Mongo profiler) and in debug logs
+func WithCorrelationID(id string) FindOption {
+	return func(opts *FindOptions) {
+		opts.CorrelationID = id
+	}
+}
+
+// WithStrictIDs makes FindByIDs fail on an invalid hex id instead of skipping it
+func WithStrictIDs(strict bool) FindOption {
+	return func(opts *FindOptions) {
+		opts.StrictIDs = strict
+	}
+}
+
+// WithReadConcern sets the read concern of the find, e.g readconcern.Linearizable()
+// linearizable reads observe every acknowledged write but are much slower
+// (each read waits on a majority round trip) and require a primary read preference
+func WithReadConcern(rc *readconcern.ReadConcern) FindOption {
+	return func(opts *FindOptions) {
+		opts.ReadConcern = rc
+	}
+}
+
+// WithSecondaryPreferred routes the find to a secondary when one is available, falling back to the primary
+// meant for reporting reads that can tolerate replication lag; not compatible with WithReadConcern(readconcern.Linearizable())
+func WithSecondaryPreferred() FindOption {
+	return func(opts *FindOptions) {
+		opts.ReadPref = readpref.SecondaryPreferred()
+	}
+}
+
+// WithCommandCapture calls fn once with the exact command document the driver sends for this find
+// surgical debugging of a single query without logging every command (WithCommandMonitor);
+// the client must be built with db.WithCommandCapture(true), otherwise the find returns db.ErrCaptureDisabled
+func WithCommandCapture(fn func(cmd bson.Raw)) FindOption {
+	return func(opts *FindOptions) {
+		opts.CommandCapture = fn
+	}
+}
+
+// NewFindOptions builds a reusable option set, e.g a standard pagination profile defined once
+// pass it to FindWithOptions or WithFindOptions; each call works on a clone so it never leaks across calls
+func NewFindOptions(opts ...FindOption) *FindOptions {
+	findOpts := &FindOptions{}
+	for _, opt := range opts {
+		opt(findOpts)
+	}
+	return findOpts
+}
+
+// Clone returns a copy of o, limit/skip and bson.M sort/projection are copied too
+func (o *FindOptions) Clone() *FindOptions {
+	if o == nil {
+		return &FindOptions{}
+	}
+
+	clone := *o
+	if o.Limit != nil {
+		limit := *o.Limit
+		clone.Limit = &limit
+	}
+	if o.Skip != nil {
+		skip := *o.Skip
+		clone.Skip = &skip
+	}
+	if sort, ok := o.Sort.(bson.M); ok {
+		clone.Sort = common.CloneBSON(sort)
+	}
+	if projection, ok := o.Projection.(bson.M); ok {
+		clone.Projection = common.CloneBSON(projection)
+	}
+	return &clone
+}
+
+// WithFindOptions applies a prebuilt option set, it replaces whatever was set before it
+// so pass it first and add per call overrides after it
+func WithFindOptions(base *FindOptions) FindOption {
+	return func(opts *FindOptions) {
+		*opts = *base.Clone()
+	}
+}
+
+// WithTextScore projects the $text relevance score into field "score" and sorts by it
+// use together with TextSearch in the filter; apply WithSort after it to override the order
+func WithTextScore() FindOption {
+	return func(opts *FindOptions) {
+		score := bson.M{"$meta": "textScore"}
+		if projection, ok := opts.Projection.(bson.M); ok {
+			// Clone so the caller's projection is not mutated
+			projection = common.CloneBSON(projection)
+			projection["score"] = score
+			opts.Projection = projection
+		} else {
+			opts.Projection = bson.M{"score": score}
+		}
+		opts.Sort = bson.D{{Key: "score", Value: score}}
+	}
+}
+
+// AggregateOption allows customizing aggregate operations
+type AggregateOption func(*AggregateOptions)
+
+type AggregateOptions struct {
+	ReadConcern              *readconcern.ReadConcern
+	ReadPref                 *readpref.ReadPref
+	BypassDocumentValidation bool
+}
+
+// WithAggregateReadConcern is the aggregate counterpart of WithReadConcern
+func WithAggregateReadConcern(rc *readconcern.ReadConcern) AggregateOption {
+	return func(opts *AggregateOptions) {
+		opts.ReadConcern = rc
+	}
+}
+
+// WithAggregateSecondaryPreferred is the aggregate counterpart of WithSecondaryPreferred
+// pipelines ending in $out or $merge always run on the primary
+func WithAggregateSecondaryPreferred() AggregateOption {
+	return func(opts *AggregateOptions) {
+		opts.ReadPref = readpref.SecondaryPreferred()
+	}
+}
+
+// WithAggregateBypassDocumentValidation is the AggregateInto ($out/$merge) counterpart of WithBypassDocumentValidation
+func WithAggregateBypassDocumentValidation(bypass bool) AggregateOption {
+	return func(opts *AggregateOptions) {
+		opts.BypassDocumentValidation = bypass
+	}
+}
+
+// CountOption allows customizing count operations
+type CountOption func(*CountOptions)
+
+type CountOptions struct {
+	Limit   *int64
+	Skip    *int64
+	Hint    any
+	MaxTime time.Duration
+}
+
+// WithCountLimit is the count counterpart of WithLimit, counting stops at limit
+func WithCountLimit(limit int64) CountOption {
+	return func(opts *CountOptions) {
+		opts.Limit = &limit
+	}
+}
+
+// WithCountSkip is the count counterpart of WithSkip
+func WithCountSkip(skip int64) CountOption {
+	return func(opts *CountOptions) {
+		opts.Skip = &skip
+	}
+}
+
+// WithCountHint forces the index used by the count, an index name or key document
+func WithCountHint(hint any) CountOption {
+	return func(opts *CountOptions) {
+		opts.Hint = hint
+	}
+}
+
+// WithCountMaxTime bounds how long the count may run, 0 or less keeps the operation timeout
+func WithCountMaxTime(d time.Duration) CountOption {
+	return func(opts *CountOptions) {
+		opts.MaxTime = d
+	}
+}
+
+// WatchOption allows customizing change streams
+type WatchOption func(*WatchOptions)
+
+type WatchOptions struct {
+	FullDocument bool
+	ResumeAfter  any
+	BatchSize    *int32
+	MaxAwaitTime *time.Duration
+}
+
+// WithFullDocument includes the current version of the document in update events (fullDocument: "updateLookup")
+func WithFullDocument() WatchOption {
+	return func(opts *WatchOptions) {
+		opts.FullDocument = true
+	}
+}
+
+// WithResumeAfter resumes the stream after token, the "_id" of the last handled event
+func WithResumeAfter(token any) WatchOption {
+	return func(opts *WatchOptions) {
+		opts.ResumeAfter = token
+	}
+}
+
+// WithWatchBatchSize sets the number of events fetched per batch
+func WithWatchBatchSize(size int32) WatchOption {
+	return func(opts *WatchOptions) {
+		opts.BatchSize = &size
+	}
+}
+
+// WithWatchMaxAwaitTime bounds how long the server waits for new events before answering an empty batch
+func WithWatchMaxAwaitTime(d time.Duration) WatchOption {
+	return func(opts *WatchOptions) {
+		opts.MaxAwaitTime = &d
+	}
+}
+
+// WriteOption allows customizing insert and delete operations
+type WriteOption func(*WriteOptions)
+
+type WriteOptions struct {
+	Unacknowledged           bool
+	BypassDocumentValidation bool
+}
+
+// WithUnacknowledged sends the write with w:0 and skips the acknowledgment check (fire-and-forget)
+// the server does not confirm the write: failures (duplicate key, validation, lost primary)
+// are silently dropped, only use it for data you can afford to lose such as metrics
+func WithUnacknowledged() WriteOption {
+	return func(opts *WriteOptions) {
+		opts.Unacknowledged = true
+	}
+}
+
+// WithBypassDocumentValidation skips the collection's validator on inserts, ignored by deletes
+// meant for trusted bulk loads (ETL, imports) whose intermediate shapes the validator would reject
+func WithBypassDocumentValidation(bypass bool) WriteOption {
+	return func(opts *WriteOptions) {
+		opts.BypassDocumentValidation = bypass
+	}
+}
+
 // UpdateOption allows customizing update operations
 type UpdateOption func(*UpdateOptions)
 
 type UpdateOptions struct {
-	Upsert *bool
+	Upsert                   *bool
+	CorrelationID            string
+	Unacknowledged           bool
+	SetOnInsert              any
+	BypassDocumentValidation bool
 }
 
 // WithUpsert sets the upsert option for update operations
@@ -75,3 +725,155 @@ func WithUpsert(upsert bool) UpdateOption {
 		opts.Upsert = &upsert
 	}
 }
+
+// WithSetOnInsert adds insert-only fields to an upsert, e.g creation timestamps
+// e.g UpdateOneSet(coll, filter, changes, ref.WithUpsert(true), ref.WithSetOnInsert(bson.M{"created_at": now}))
+// not supported by the pipeline updates
+func WithSetOnInsert(fields any) UpdateOption {
+	return func(opts *UpdateOptions) {
+		opts.SetOnInsert = fields
+	}
+}
+
+// WithUpdateUnacknowledged is the update counterpart of WithUnacknowledged
+func WithUpdateUnacknowledged() UpdateOption {
+	return func(opts *UpdateOptions) {
+		opts.Unacknowledged = true
+	}
+}
+
+// WithUpdateCorrelationID is the update counterpart of WithCorrelationID
+func WithUpdateCorrelationID(id string) UpdateOption {
+	return func(opts *UpdateOptions) {
+		opts.CorrelationID = id
+	}
+}
+
+// WithUpdateBypassDocumentValidation is the update counterpart of WithBypassDocumentValidation
+func WithUpdateBypassDocumentValidation(bypass bool) UpdateOption {
+	return func(opts *UpdateOptions) {
+		opts.BypassDocumentValidation = bypass
+	}
+}
+
+// CollectionOption allows customizing collection creation
+type CollectionOption func(*CollectionOptions)
+
+type CollectionOptions struct {
+	Capped       *bool
+	SizeInBytes  *int64
+	MaxDocuments *int64
+	Validator    any
+	TimeSeries   *TimeSeries
+}
+
+// TimeSeries holds the time-series settings of a collection (MongoDB 5.0+)
+type TimeSeries struct {
+	TimeField   string
+	MetaField   string // optional
+	Granularity string // optional: "seconds", "minutes" or "hours"
+}
+
+// WithCapped creates a capped collection with a maximum size in bytes
+// maxDocs is ignored when it is 0 or less
+func WithCapped(sizeBytes int64, maxDocs int64) CollectionOption {
+	return func(opts *CollectionOptions) {
+		capped := true
+		opts.Capped = &capped
+		opts.SizeInBytes = &sizeBytes
+		if maxDocs > 0 {
+			opts.MaxDocuments = &maxDocs
+		}
+	}
+}
+
+// WithValidator sets the validation rules (e.g. $jsonSchema) for the collection
+func WithValidator(validator bson.M) CollectionOption {
+	return func(opts *CollectionOptions) {
+		opts.Validator = validator
+	}
+}
+
+// WithTimeSeries creates a time-series collection, metaField and granularity are skipped when empty
+func WithTimeSeries(timeField, metaField, granularity string) CollectionOption {
+	return func(opts *CollectionOptions) {
+		opts.TimeSeries = &TimeSeries{
+			TimeField:   timeField,
+			MetaField:   metaField,
+			Granularity: granularity,
+		}
+	}
+}
+
+// IndexOption allows customizing index creation
+type IndexOption func(*IndexOptions)
+
+type IndexOptions struct {
+	Name                    *string
+	Unique                  *bool
+	Weights                 any
+	DefaultLanguage         *string
+	ExpireAfter             *time.Duration
+	PartialFilterExpression any
+	Sparse                  *bool
+}
+
+// WithIndexName sets the name of the index
+func WithIndexName(name string) IndexOption {
+	return func(opts *IndexOptions) {
+		opts.Name = &name
+	}
+}
+
+// WithUnique sets whether the index rejects duplicate values
+func WithUnique(unique bool) IndexOption {
+	return func(opts *IndexOptions) {
+		opts.Unique = &unique
+	}
+}
+
+// WithTextWeights sets the relevance weight of each field in a text index
+func WithTextWeights(weights bson.M) IndexOption {
+	return func(opts *IndexOptions) {
+		opts.Weights = weights
+	}
+}
+
+// WithDefaultLanguage sets the language used for stemming in a text index
+func WithDefaultLanguage(language string) IndexOption {
+	return func(opts *IndexOptions) {
+		opts.DefaultLanguage = &language
+	}
+}
+
+// WithExpireAfter turns the index into a TTL index, documents expire ttl after the indexed date
+// ttl is truncated to whole seconds, CreateIndex rejects it under 1s or over math.MaxInt32 seconds
+func WithExpireAfter(ttl time.Duration) IndexOption {
+	return func(opts *IndexOptions) {
+		opts.ExpireAfter = &ttl
+	}
+}
+
+// WithPartialFilterExpression only indexes documents matching filter
+// e.g unique email only when present: WithPartialFilterExpression(bson.M{"email": bson.M{"$exists": true}})
+func WithPartialFilterExpression(filter bson.M) IndexOption {
+	return func(opts *IndexOptions) {
+		opts.PartialFilterExpression = filter
+	}
+}
+
+// WithSparse skips documents missing the indexed field
+func WithSparse(sparse bool) IndexOption {
+	return func(opts *IndexOptions) {
+		opts.Sparse = &sparse
+	}
+}
+
+// CollStats holds the sizing information returned by the collStats command, sizes are in bytes
+type CollStats struct {
+	Count          int64   `bson:"count"`
+	Size           int64   `bson:"size"`
+	StorageSize    int64   `bson:"storageSize"`
+	AvgObjSize     float64 `bson:"avgObjSize"`
+	TotalIndexSize int64   `bson:"totalIndexSize"`
+}
@@ -0,0 +1,49 @@
+package ref
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type profile struct {
+	Name string `bson:"name"`
+	Age  int    `bson:"age"`
+}
+
+func TestUpdateCombineStructPayload(t *testing.T) {
+	want := bson.M{"$set": bson.M{"name": "ann", "age": int32(30), "active": true}}
+
+	tests := []struct {
+		name    string
+		updates []any
+	}{
+		{"struct first", []any{UpdateSet(profile{Name: "ann", Age: 30}), bson.M{"$set": bson.M{"active": true}}}},
+		{"struct last", []any{bson.M{"$set": bson.M{"active": true}}, UpdateSet(profile{Name: "ann", Age: 30})}},
+		{"struct pointer", []any{UpdateSet(&profile{Name: "ann", Age: 30}), bson.M{"$set": bson.M{"active": true}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UpdateCombine(tt.updates...); !reflect.DeepEqual(got, want) {
+				t.Fatalf("UpdateCombine() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUpdateCombineMaps(t *testing.T) {
+	got := UpdateCombine(bson.M{"$set": bson.M{"a": 1}}, bson.D{{Key: "$set", Value: bson.M{"b": 2}}, {Key: "$inc", Value: bson.M{"n": 1}}})
+	want := bson.M{"$set": bson.M{"a": 1, "b": 2}, "$inc": bson.M{"n": 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("UpdateCombine() = %v, want %v", got, want)
+	}
+}
+
+func TestToDocumentNeverNil(t *testing.T) {
+	for _, v := range []any{nil, 42, "text"} {
+		if doc := toDocument(v); doc == nil {
+			t.Fatalf("toDocument(%v) = nil", v)
+		}
+	}
+}
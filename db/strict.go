@@ -0,0 +1,33 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownCollection is returned in strict mode for a collection name missing from the allowlist
+var ErrUnknownCollection = errors.New("unknown collection")
+
+// WithStrictCollections only allows operations on the listed collections, anything else fails
+// with ErrUnknownCollection instead of silently creating a new collection on first write (e.g "usesr")
+func WithStrictCollections(knownNames ...string) MongoOption {
+	return func(m *MongoLib) {
+		m.known = make(map[string]struct{}, len(knownNames))
+		for _, name := range knownNames {
+			m.known[name] = struct{}{}
+		}
+	}
+}
+
+// checkCollections validates names against the allowlist, a no-op unless strict mode is enabled
+func (m *MongoLib) checkCollections(names ...string) error {
+	if m.known == nil {
+		return nil
+	}
+	for _, name := range names {
+		if _, ok := m.known[name]; !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownCollection, name)
+		}
+	}
+	return nil
+}
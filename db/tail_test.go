@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ranggadablues/gosok/db/ref"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestStreamCursorStopsOnCancel(t *testing.T) {
+	docs := make([]any, 1000)
+	for i := range docs {
+		docs[i] = bson.M{"_id": i}
+	}
+	cursor, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan bson.M)
+	done := make(chan error, 1)
+	var lastID any
+	go func() { done <- streamCursor(ctx, cursor, out, &lastID) }()
+
+	// Cancel mid-stream, after a few documents
+	for i := 0; i < 3; i++ {
+		<-out
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("streamCursor() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("streamCursor() did not stop after cancellation")
+	}
+	if lastID.(int32) >= int32(len(docs)-1) {
+		t.Fatalf("stream ran to completion, last _id = %v", lastID)
+	}
+}
+
+func TestTailReturnsContextCanceled(t *testing.T) {
+	if os.Getenv("MONGO_URI") == "" || os.Getenv("MONGO_DB_NAME") == "" {
+		t.Skip("MONGO_URI and MONGO_DB_NAME are required")
+	}
+
+	m, ok := NewMongoWithOptions().(*MongoLib)
+	if !ok {
+		t.Fatal("NewMongoWithOptions returned nil")
+	}
+	defer m.Close()
+
+	const coll = "gosok_tail_cancel"
+	m.DropCollection(coll)
+	if err := m.CreateCollection(coll, ref.WithCapped(1<<20, 0)); err != nil {
+		t.Fatal(err)
+	}
+	defer m.DropCollection(coll)
+	for i := 0; i < 10; i++ {
+		if _, err := m.InsertOne(coll, bson.M{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan bson.M)
+	done := make(chan error, 1)
+	go func() { done <- m.WithContext(ctx).Tail(coll, bson.M{}, out, nil) }()
+
+	<-out
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Tail() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Tail() did not stop after cancellation")
+	}
+}
+
+func TestResumeFilter(t *testing.T) {
+	after := bson.M{"_id": bson.M{"$gt": 7}}
+	filter := bson.M{"level": "error"}
+
+	tests := []struct {
+		name   string
+		filter any
+		lastID any
+		want   any
+	}{
+		{"nil filter", nil, nil, bson.M{}},
+		{"nil filter resumed", nil, 7, after},
+		{"empty bson.M resumed", bson.M{}, 7, after},
+		{"empty bson.D resumed", bson.D{}, 7, after},
+		{"filter", filter, nil, filter},
+		{"filter resumed", filter, 7, bson.M{"$and": bson.A{filter, after}}},
+	}
+	for _, tt := range tests {
+		if got := resumeFilter(tt.filter, tt.lastID); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: resumeFilter() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
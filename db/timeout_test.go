@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ranggadablues/gosok/common"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestAdminOperationsWrapTimeout(t *testing.T) {
+	if os.Getenv("MONGO_URI") == "" || os.Getenv("MONGO_DB_NAME") == "" {
+		t.Skip("MONGO_URI and MONGO_DB_NAME are required")
+	}
+
+	m := NewMongoWithOptions()
+	if m == nil {
+		t.Fatal("NewMongoWithOptions returned nil")
+	}
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	expired := m.(*MongoLib).WithContext(ctx)
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	ops := map[string]func() error{
+		"ListCollections": func() error { _, err := expired.ListCollections(); return err },
+		"CollectionStats": func() error { _, err := expired.CollectionStats("gosok_timeout"); return err },
+		"CreateIndex": func() error {
+			_, err := expired.CreateIndex("gosok_timeout", bson.D{{Key: "a", Value: 1}})
+			return err
+		},
+		"EnsureTTLIndex":    func() error { return expired.EnsureTTLIndex("gosok_timeout", "at", time.Hour) },
+		"EnsureUniqueIndex": func() error { return expired.EnsureUniqueIndex("gosok_timeout", bson.D{{Key: "b", Value: 1}}) },
+		"Tail":              func() error { return expired.Tail("gosok_timeout", nil, make(chan bson.M), stop) },
+	}
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, common.ErrTimeout) {
+			t.Errorf("%s() err = %v, want common.ErrTimeout", name, err)
+		}
+	}
+}
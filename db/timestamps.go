@@ -0,0 +1,129 @@
+package db
+
+import (
+	"time"
+
+	"github.com/ranggadablues/gosok/db/ref"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// WithTimestamps stamps createdField on inserts and updatedField on updates (opt-in)
+// an empty field name disables that stamp; fields already set by the caller are kept
+// e.g db.NewMongoWithOptions(db.WithTimestamps("created_at", "updated_at"))
+func WithTimestamps(createdField, updatedField string) MongoOption {
+	return func(m *MongoLib) {
+		m.createdField = createdField
+		m.updatedField = updatedField
+	}
+}
+
+// stampInsert sets createdField on a copy of document unless it is already set
+// structs are converted to bson.D so the field can be added
+func (m *MongoLib) stampInsert(document any) any {
+	if m.createdField == "" {
+		return document
+	}
+
+	doc, ok := toBSOND(document)
+	if !ok {
+		return document
+	}
+	for i, elem := range doc {
+		if elem.Key != m.createdField {
+			continue
+		}
+		if !isUnsetTime(elem.Value) {
+			return doc
+		}
+		doc[i].Value = time.Now().UTC()
+		return doc
+	}
+	return append(doc, bson.E{Key: m.createdField, Value: time.Now().UTC()})
+}
+
+// stampUpdate adds updatedField to an update unless the update already sets it
+// operator updates get {$currentDate: {field: true}}, pipeline updates get a {$set: {field: "$$NOW"}} stage
+func (m *MongoLib) stampUpdate(update any) any {
+	if m.updatedField == "" {
+		return update
+	}
+
+	if pipeline, ok := update.([]bson.M); ok {
+		for _, stage := range pipeline {
+			if setsField(stage["$set"], m.updatedField) {
+				return update
+			}
+		}
+		stamped := append([]bson.M{}, pipeline...)
+		return append(stamped, bson.M{"$set": bson.M{m.updatedField: "$$NOW"}})
+	}
+
+	doc, ok := update.(bson.M)
+	if !ok {
+		return update
+	}
+	if setsField(doc["$set"], m.updatedField) || setsField(doc["$currentDate"], m.updatedField) {
+		return update
+	}
+	return ref.UpdateCombine(doc, bson.M{"$currentDate": bson.M{m.updatedField: true}})
+}
+
+// setsField reports whether an operator document contains field
+func setsField(fields any, field string) bool {
+	doc, ok := toBSOND(fields)
+	if !ok {
+		return false
+	}
+	for _, elem := range doc {
+		if elem.Key == field {
+			return true
+		}
+	}
+	return false
+}
+
+// toBSOND returns a copy of a document (map, bson.D or struct) as bson.D
+func toBSOND(v any) (bson.D, bool) {
+	switch val := v.(type) {
+	case nil:
+		return nil, false
+	case bson.D:
+		return append(bson.D{}, val...), true
+	case bson.M:
+		return mapToBSOND(val), true
+	case map[string]any:
+		return mapToBSOND(val), true
+	}
+
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+func mapToBSOND(in map[string]any) bson.D {
+	doc := make(bson.D, 0, len(in))
+	for k, v := range in {
+		doc = append(doc, bson.E{Key: k, Value: v})
+	}
+	return doc
+}
+
+// isUnsetTime reports whether a timestamp value is missing (nil or zero time)
+func isUnsetTime(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case time.Time:
+		return val.IsZero()
+	case bson.DateTime:
+		return val.Time().IsZero()
+	default:
+		return false
+	}
+}
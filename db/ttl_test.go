@@ -0,0 +1,38 @@
+package db
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ranggadablues/gosok/db/ref"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestEnsureTTLIndexRejectsOutOfRange(t *testing.T) {
+	m := newMongoLib()
+	for _, ttl := range []time.Duration{
+		0,
+		-time.Hour,
+		500 * time.Millisecond,
+		time.Duration(math.MaxInt32+1) * time.Second,
+	} {
+		if err := m.EnsureTTLIndex("sessions", "expiresAt", ttl); !errors.Is(err, ErrInvalidTTL) {
+			t.Errorf("EnsureTTLIndex(%s) err = %v, want ErrInvalidTTL", ttl, err)
+		}
+	}
+}
+
+func TestCreateIndexRejectsOutOfRangeExpireAfter(t *testing.T) {
+	m := newMongoLib()
+	for _, ttl := range []time.Duration{
+		500 * time.Millisecond,
+		time.Duration(math.MaxInt32+1) * time.Second,
+	} {
+		_, err := m.CreateIndex("sessions", bson.D{{Key: "expiresAt", Value: 1}}, ref.WithExpireAfter(ttl))
+		if !errors.Is(err, ErrInvalidTTL) {
+			t.Errorf("CreateIndex(WithExpireAfter(%s)) err = %v, want ErrInvalidTTL", ttl, err)
+		}
+	}
+}
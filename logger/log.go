@@ -2,9 +2,11 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -17,20 +19,40 @@ type ILogLevel interface {
 	LogWarnLevel(keyvals ...interface{})
 	LogErrorLevel(keyvals ...interface{})
 	LogDebugLevel(keyvals ...interface{})
-	LogDebugLevelWithCaller(msg string)
+	LogDebugLevelWithCaller(msg string, keyvals ...interface{})
+	ErrorReturn(err error, keyvals ...interface{}) error
 	UTC() *LogLevel
+	WithErrorSampling(n int, window time.Duration) *LogLevel
+	WithSampler(s *ErrorSampler) *LogLevel
 }
 
 type LogLevel struct {
-	logger log.Logger
-	isUTC  bool
+	logger     log.Logger
+	isUTC      bool
+	writers    []io.Writer
+	timeFormat string
+	sampler    *ErrorSampler
 }
 
 func NewLogger() ILogLevel {
-	logger := setNewLogger(false)
+	logger := setNewLogger(false, "")
 	return &LogLevel{logger: logger, isUTC: false}
 }
 
+// NewLoggerWithTimeFormat formats the ts field with layout, e.g time.RFC1123 or "2006-01-02 15:04:05.000"
+// common.TimeFormatUnix, TimeFormatUnixMilli, TimeFormatUnixMicro and TimeFormatUnixNano log epoch numbers
+func NewLoggerWithTimeFormat(layout string, utc bool) ILogLevel {
+	logger := setNewLogger(utc, layout)
+	return &LogLevel{logger: logger, isUTC: utc, timeFormat: layout}
+}
+
+// NewLoggerWithWriters logs to every writer, e.g logger.NewLoggerWithWriters(os.Stdout, file)
+// terminals keep the colored logfmt output, other writers are teed via io.MultiWriter as plain logfmt
+func NewLoggerWithWriters(ws ...io.Writer) ILogLevel {
+	logger := setNewLogger(false, "", ws...)
+	return &LogLevel{logger: logger, isUTC: false, writers: ws}
+}
+
 func (l *LogLevel) UTC() *LogLevel {
 	l.isUTC = true
 	return l
@@ -38,21 +60,77 @@ func (l *LogLevel) UTC() *LogLevel {
 
 func (l *LogLevel) defaultLogTime() *LogLevel {
 	if l.isUTC {
-		l.logger = setNewLogger(l.isUTC)
+		l.logger = setNewLogger(l.isUTC, l.timeFormat, l.writers...)
 	}
 	return l
 }
 
-func setNewLogger(isUTC bool) log.Logger {
-	logTime := log.DefaultTimestamp
-	if isUTC {
-		logTime = log.DefaultTimestampUTC
-	}
-	logger := term.NewLogger(os.Stdout, log.NewLogfmtLogger, ColorInit)
+func setNewLogger(isUTC bool, layout string, ws ...io.Writer) log.Logger {
+	logTime := timestampValuer(isUTC, layout)
+	logger := newWritersLogger(ws)
 	logger = log.With(logger, "ts", logTime, "caller", log.Caller(4))
 	return logger
 }
 
+// timestampValuer returns the ts valuer for layout, go-kit's RFC3339Nano default when layout is empty
+func timestampValuer(isUTC bool, layout string) log.Valuer {
+	now := time.Now
+	if isUTC {
+		now = func() time.Time { return time.Now().UTC() }
+	}
+
+	switch layout {
+	case "":
+		if isUTC {
+			return log.DefaultTimestampUTC
+		}
+		return log.DefaultTimestamp
+	case common.TimeFormatUnix:
+		return func() interface{} { return now().Unix() }
+	case common.TimeFormatUnixMilli:
+		return func() interface{} { return now().UnixMilli() }
+	case common.TimeFormatUnixMicro:
+		return func() interface{} { return now().UnixMicro() }
+	case common.TimeFormatUnixNano:
+		return func() interface{} { return now().UnixNano() }
+	default:
+		return log.TimestampFormat(now, layout)
+	}
+}
+
+// newWritersLogger builds a colored logger per terminal and a single plain one teeing the other writers
+func newWritersLogger(ws []io.Writer) log.Logger {
+	if len(ws) == 0 {
+		ws = []io.Writer{os.Stdout}
+	}
+
+	var loggers []log.Logger
+	var plain []io.Writer
+	for _, w := range ws {
+		if term.IsTerminal(w) {
+			loggers = append(loggers, term.NewLogger(w, log.NewLogfmtLogger, ColorInit))
+			continue
+		}
+		plain = append(plain, w)
+	}
+	if len(plain) > 0 {
+		loggers = append(loggers, log.NewLogfmtLogger(io.MultiWriter(plain...)))
+	}
+
+	if len(loggers) == 1 {
+		return loggers[0]
+	}
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		var err error
+		for _, logger := range loggers {
+			if logErr := logger.Log(keyvals...); logErr != nil && err == nil {
+				err = logErr
+			}
+		}
+		return err
+	})
+}
+
 func (l *LogLevel) LogInfoLevel(keyvals ...interface{}) {
 	l.defaultLogTime()
 	level.Info(l.logger).Log(keyvals...)
@@ -64,6 +142,10 @@ func (l *LogLevel) LogWarnLevel(keyvals ...interface{}) {
 }
 
 func (l *LogLevel) LogErrorLevel(keyvals ...interface{}) {
+	keyvals, ok := l.sampleError(keyvals)
+	if !ok {
+		return
+	}
 	l.defaultLogTime()
 	level.Error(l.logger).Log(keyvals...)
 }
@@ -73,14 +155,40 @@ func (l *LogLevel) LogDebugLevel(keyvals ...interface{}) {
 	level.Debug(l.logger).Log(keyvals...)
 }
 
-func (l *LogLevel) LogDebugLevelWithCaller(msg string) {
+// ErrorReturn logs err at error level with keyvals and returns it unchanged
+// e.g return logger.NewLogger().ErrorReturn(err, "msg", "insert failed")
+func (l *LogLevel) ErrorReturn(err error, keyvals ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	keyvals, ok := l.sampleError(append(keyvals, "err", err.Error()))
+	if !ok {
+		return err
+	}
+	l.defaultLogTime()
+	level.Error(l.logger).Log(keyvals...)
+	return err
+}
+
+// sampleError applies WithErrorSampling, false when the line must be dropped
+func (l *LogLevel) sampleError(keyvals []interface{}) ([]interface{}, bool) {
+	ok, suppressed := l.sampler.sample(keyvals)
+	if ok && suppressed > 0 {
+		keyvals = append(keyvals, "suppressed", suppressed)
+	}
+	return keyvals, ok
+}
+
+// LogDebugLevelWithCaller logs msg with the caller location, extra keyvals are appended
+func (l *LogLevel) LogDebugLevelWithCaller(msg string, keyvals ...interface{}) {
 	l.defaultLogTime()
 	file, line, fn := getCallerInfo(3)
-	level.Warn(l.logger).Log(
+	kv := []interface{}{
 		"query", msg,
 		"from", fmt.Sprintf("%s:%d", file, line),
 		"func", fn,
-	)
+	}
+	level.Debug(l.logger).Log(append(kv, keyvals...)...)
 }
 
 func ColorInit(keyvals ...interface{}) term.FgBgColor {
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrorSampler keeps the per message counters of WithErrorSampling,
+// share one between loggers with WithSampler so short-lived loggers sample together
+type ErrorSampler struct {
+	mu     sync.Mutex
+	every  int
+	window time.Duration
+	seen   map[string]*sampleWindow
+	pruned time.Time
+}
+
+type sampleWindow struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// NewErrorSampler logs only 1 of every n identical error lines within window, nil (no sampling) when n <= 1
+// e.g s := logger.NewErrorSampler(100, time.Minute) then logger.NewLogger().WithSampler(s) on each call
+func NewErrorSampler(n int, window time.Duration) *ErrorSampler {
+	if n <= 1 || window <= 0 {
+		return nil
+	}
+	return &ErrorSampler{
+		every:  n,
+		window: window,
+		seen:   map[string]*sampleWindow{},
+	}
+}
+
+// WithErrorSampling logs only 1 of every n identical error lines within window, keyed by the keyvals
+// so a failing dependency can't flood the output; the next logged line carries the "suppressed" count
+// e.g logger.NewLogger().WithErrorSampling(100, time.Minute), n <= 1 disables sampling
+// the counters live on this logger only, use NewErrorSampler and WithSampler for loggers created per call
+func (l *LogLevel) WithErrorSampling(n int, window time.Duration) *LogLevel {
+	l.sampler = NewErrorSampler(n, window)
+	return l
+}
+
+// WithSampler makes l share the counters of s, nil disables sampling
+func (l *LogLevel) WithSampler(s *ErrorSampler) *LogLevel {
+	l.sampler = s
+	return l
+}
+
+// sample reports whether keyvals should be logged and how many identical lines were dropped before it
+func (s *ErrorSampler) sample(keyvals []interface{}) (bool, int) {
+	if s == nil {
+		return true, 0
+	}
+
+	key := fmt.Sprint(keyvals...)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forget expired windows once per window so one-off messages don't accumulate;
+	// a window with suppressed lines is kept one more window to report its count
+	if now.Sub(s.pruned) >= s.window {
+		for k, w := range s.seen {
+			age := now.Sub(w.start)
+			if age >= 2*s.window || (age >= s.window && w.suppressed == 0) {
+				delete(s.seen, k)
+			}
+		}
+		s.pruned = now
+	}
+
+	w, ok := s.seen[key]
+	if !ok || now.Sub(w.start) >= s.window {
+		suppressed := 0
+		if ok {
+			suppressed = w.suppressed
+		}
+		s.seen[key] = &sampleWindow{start: now, count: 1}
+		return true, suppressed
+	}
+
+	w.count++
+	if (w.count-1)%s.every != 0 {
+		w.suppressed++
+		return false, 0
+	}
+	suppressed := w.suppressed
+	w.suppressed = 0
+	return true, suppressed
+}
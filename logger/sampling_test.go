@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestErrorSamplerSharedAcrossLoggers(t *testing.T) {
+	s := NewErrorSampler(3, time.Minute)
+
+	var logged int
+	for i := 0; i < 6; i++ {
+		// a fresh logger per call, as the db package does
+		l := NewLogger().UTC().WithSampler(s)
+		if _, ok := l.sampleError([]interface{}{"msg", "boom"}); ok {
+			logged++
+		}
+	}
+	if logged != 2 {
+		t.Fatalf("logged %d of 6 lines, want 2", logged)
+	}
+}
+
+func TestErrorSamplerPrunesExpiredWindows(t *testing.T) {
+	window := 10 * time.Millisecond
+	s := NewErrorSampler(2, window)
+
+	// leave a suppressed line behind so the old code kept the key forever
+	s.sample([]interface{}{"msg", "a"})
+	s.sample([]interface{}{"msg", "a"})
+	s.sample([]interface{}{"msg", "b"})
+
+	time.Sleep(2 * window)
+	s.sample([]interface{}{"msg", "c"})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen["msga"]; ok {
+		t.Fatal("expired window with suppressed lines was not pruned")
+	}
+	if len(s.seen) != 1 {
+		t.Fatalf("seen has %d keys, want 1", len(s.seen))
+	}
+}
+
+func TestNewErrorSamplerDisabled(t *testing.T) {
+	if s := NewErrorSampler(1, time.Minute); s != nil {
+		t.Fatal("n <= 1 must disable sampling")
+	}
+	var s *ErrorSampler
+	if ok, _ := s.sample([]interface{}{"msg"}); !ok {
+		t.Fatal("nil sampler must log every line")
+	}
+}